@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"refund-demo/internal/config"
+	"refund-demo/internal/store"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Creates an admin API key for the /internal routes. Use this to bootstrap the
+// first key with the admin scope; further keys can be issued via POST /internal/keys.
+func main() {
+	// Setup logging
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	name := flag.String("name", "", "Descriptive name for the key (required)")
+	scopes := flag.String("scopes", store.ScopeAdmin, "Comma-separated scopes to grant")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	if *name == "" {
+		log.Fatal().Msg("-name is required")
+	}
+
+	db, err := store.ConnectDB(cfg.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to connect to database")
+	}
+	defer db.Close()
+
+	var scopeList []string
+	for _, s := range strings.Split(*scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopeList = append(scopeList, s)
+		}
+	}
+
+	raw, key, err := store.CreateAPIKey(context.Background(), db, *name, scopeList)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create API key")
+	}
+
+	log.Info().Str("key_id", key.KeyID).Strs("scopes", key.Scopes).Msg("API key created - store it now, it cannot be shown again")
+	fmt.Println(raw)
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"refund-demo/internal/config"
+	"refund-demo/internal/openapi"
+	"refund-demo/internal/store"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Drives every operation in openapi.yaml against a running server and checks
+// each response, including every SSE event, against the spec. It exits non-zero
+// if any response doesn't match or an operation was never exercised, so it can
+// gate CI once the stack is up (see `make contract`).
+func main() {
+	// Setup logging
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	baseURL := flag.String("base-url", "http://localhost:8080", "Server under test")
+	adminURL := flag.String("admin-url", "", "Admin listener for /internal and /metrics (default: -base-url)")
+	apiKey := flag.String("api-key", os.Getenv("CONTRACT_API_KEY"), "API key with the admin scope (or use CONTRACT_API_KEY env)")
+	token := flag.String("token", os.Getenv("CONTRACT_TOKEN"), "Bearer token for /v1 (default: minted from JWT_HS256_SECRET)")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	if *apiKey == "" {
+		log.Fatal().Msg("-api-key is required (create one with `make apikey NAME=contract`)")
+	}
+	if *adminURL == "" {
+		*adminURL = *baseURL
+	}
+	if *token == "" && cfg.Auth.HS256Secret != "" {
+		*token, err = mintToken(cfg.Auth)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to mint bearer token")
+		}
+	}
+
+	spec, err := openapi.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load OpenAPI spec")
+	}
+
+	r := &runner{
+		spec:     spec,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		base:     strings.TrimRight(*baseURL, "/"),
+		admin:    strings.TrimRight(*adminURL, "/"),
+		token:    *token,
+		apiKey:   *apiKey,
+		covered:  make(map[string]bool),
+		authless: cfg.Auth.Disabled,
+	}
+	r.run()
+
+	for _, id := range spec.Operations() {
+		if !r.covered[id] {
+			r.failf("operation %s was not exercised", id)
+		}
+	}
+	if r.failures > 0 {
+		fmt.Printf("\n%d contract failure(s)\n", r.failures)
+		os.Exit(1)
+	}
+	fmt.Printf("\nall %d operations match the contract\n", len(spec.Operations()))
+}
+
+// runner sends requests and records which operations matched the spec
+type runner struct {
+	spec     *openapi.Spec
+	client   *http.Client
+	base     string
+	admin    string
+	token    string
+	apiKey   string
+	authless bool
+
+	covered  map[string]bool
+	failures int
+}
+
+// call is one request in the suite
+type call struct {
+	method string
+	url    string
+	body   interface{}
+	header map[string]string
+	// wantStatus fails the check if the server answers with another status
+	wantStatus int
+	// stream names the schema every SSE data payload must match
+	stream string
+}
+
+// result is the response to a call
+type result struct {
+	status int
+	header http.Header
+	body   []byte
+	events [][]byte
+}
+
+func (r *runner) run() {
+	admin := map[string]string{"X-API-Key": r.apiKey}
+	bearer := map[string]string{}
+	if r.token != "" {
+		bearer["Authorization"] = "Bearer " + r.token
+	}
+
+	r.do(call{method: "GET", url: r.base + "/livez", wantStatus: 200})
+	r.do(call{method: "GET", url: r.base + "/readyz"})
+	r.do(call{method: "GET", url: r.base + "/health"})
+	r.do(call{method: "GET", url: r.base + "/openapi.json", wantStatus: 200})
+	r.do(call{method: "GET", url: r.base + "/docs", wantStatus: 200})
+	r.do(call{method: "GET", url: r.admin + "/metrics", wantStatus: 200})
+
+	// A fresh return owned by the demo user backs the /v1 checks
+	scraped := r.do(call{method: "POST", url: r.admin + "/internal/scrape", header: admin, wantStatus: 200})
+	var created struct {
+		ReturnID string `json:"return_id"`
+	}
+	if scraped == nil || json.Unmarshal(scraped.body, &created) != nil || created.ReturnID == "" {
+		r.failf("could not create a return to test against; skipping /v1 checks")
+		return
+	}
+	id := created.ReturnID
+
+	// Conditional requests need the validator from a successful read
+	var etag string
+	var current struct {
+		FilingID string `json:"filing_id"`
+	}
+	if status := r.do(call{method: "GET", url: r.base + "/v1/status/" + id, header: bearer, wantStatus: 200}); status != nil {
+		etag = status.header.Get("ETag")
+		json.Unmarshal(status.body, &current)
+	}
+	if etag != "" {
+		r.do(call{method: "GET", url: r.base + "/v1/status/" + id, wantStatus: 304,
+			header: merge(bearer, map[string]string{"If-None-Match": etag})})
+	}
+	r.do(call{method: "GET", url: r.base + "/v1/status/" + store.NewULID(), header: bearer, wantStatus: 404})
+	if !r.authless {
+		r.do(call{method: "GET", url: r.base + "/v1/status/" + id, wantStatus: 401})
+	}
+
+	if current.FilingID != "" {
+		r.do(call{method: "GET", url: r.base + "/v1/filings/" + current.FilingID, header: bearer, wantStatus: 200})
+	}
+	r.do(call{method: "GET", url: r.base + "/v1/filings/" + store.NewULID(), header: bearer, wantStatus: 404})
+
+	r.do(call{method: "GET", url: r.base + "/v1/status/" + id + "/stream", header: bearer, wantStatus: 200, stream: "StatusStreamEvent"})
+	r.do(call{method: "POST", url: r.base + "/v1/status/explain", header: bearer, wantStatus: 200, stream: "ExplainEvent",
+		body: map[string]string{"return_id": id, "question": "Why is my refund delayed?"}})
+
+	if etag != "" {
+		r.do(call{method: "POST", url: r.admin + "/internal/returns/" + id + "/transition", wantStatus: 200,
+			header: merge(admin, map[string]string{"If-Match": etag}), body: map[string]string{"status": store.StatusSent}})
+		r.do(call{method: "POST", url: r.admin + "/internal/returns/" + id + "/transition", wantStatus: 412,
+			header: merge(admin, map[string]string{"If-Match": etag}), body: map[string]string{"status": store.StatusCompleted}})
+	}
+	offset := map[string]interface{}{"kind": store.AdjustmentOffset, "amount_cents": -12500,
+		"reason_code": store.ReasonFederalDebt, "agency": "U.S. Department of Education"}
+	r.do(call{method: "POST", url: r.admin + "/internal/returns/" + id + "/adjustments", header: admin, wantStatus: 201, body: offset})
+	r.do(call{method: "POST", url: r.admin + "/internal/returns/" + id + "/adjustments", header: admin, wantStatus: 400,
+		body: map[string]interface{}{"kind": store.AdjustmentOffset, "amount_cents": 12500}})
+	if etag != "" {
+		r.do(call{method: "POST", url: r.admin + "/internal/returns/" + id + "/adjustments", wantStatus: 412,
+			header: merge(admin, map[string]string{"If-Match": etag}), body: offset})
+	}
+	// The status response now carries the adjustment and refund summary
+	r.do(call{method: "GET", url: r.base + "/v1/status/" + id, header: bearer, wantStatus: 200})
+
+	hook := r.do(call{method: "POST", url: r.admin + "/internal/webhooks", header: admin, wantStatus: 201,
+		body: map[string]interface{}{"url": "https://example.com/hooks/contract", "events": []string{}}})
+	r.do(call{method: "GET", url: r.admin + "/internal/webhooks", header: admin, wantStatus: 200})
+	r.do(call{method: "GET", url: r.admin + "/internal/webhooks/deliveries?status=DEAD&limit=5", header: admin, wantStatus: 200})
+	r.do(call{method: "POST", url: r.admin + "/internal/webhooks/deliveries/" + store.NewULID() + "/retry", header: admin, wantStatus: 404})
+	var subscribed struct {
+		Subscription struct {
+			SubscriptionID string `json:"subscription_id"`
+		} `json:"subscription"`
+	}
+	if hook != nil && json.Unmarshal(hook.body, &subscribed) == nil {
+		r.do(call{method: "DELETE", url: r.admin + "/internal/webhooks/" + subscribed.Subscription.SubscriptionID, header: admin, wantStatus: 204})
+	}
+
+	key := r.do(call{method: "POST", url: r.admin + "/internal/keys", header: admin, wantStatus: 201,
+		body: map[string]interface{}{"name": "contract", "scopes": []string{store.ScopeWebhooksRead}}})
+	r.do(call{method: "GET", url: r.admin + "/internal/keys", header: admin, wantStatus: 200})
+	var issued struct {
+		Key struct {
+			KeyID string `json:"key_id"`
+		} `json:"key"`
+	}
+	if key != nil && json.Unmarshal(key.body, &issued) == nil {
+		r.do(call{method: "DELETE", url: r.admin + "/internal/keys/" + issued.Key.KeyID, header: admin, wantStatus: 204})
+	}
+	r.do(call{method: "GET", url: r.admin + "/internal/reports/eta-accuracy?tax_year=2024", header: admin, wantStatus: 200})
+	r.do(call{method: "GET", url: r.admin + "/internal/reports/eta-accuracy?tax_year=soon", header: admin, wantStatus: 400})
+	r.do(call{method: "GET", url: r.admin + "/internal/reports/eta-calibration", header: admin, wantStatus: 200})
+	r.do(call{method: "GET", url: r.admin + "/internal/reports/eta-calibration?format=csv", header: admin, wantStatus: 200})
+	r.do(call{method: "GET", url: r.admin + "/internal/reports/eta-calibration?format=xml", header: admin, wantStatus: 400})
+	r.do(call{method: "GET", url: r.admin + "/internal/returns/stalled", header: admin, wantStatus: 200})
+	r.do(call{method: "GET", url: r.admin + "/internal/returns/stalled?status=REVIEW&limit=10", header: admin, wantStatus: 200})
+	r.do(call{method: "GET", url: r.admin + "/internal/returns/stalled?status=FILED", header: admin, wantStatus: 400})
+	r.do(call{method: "GET", url: r.admin + "/internal/audit?limit=10", header: admin, wantStatus: 200})
+	r.do(call{method: "GET", url: r.admin + "/internal/audit", wantStatus: 401})
+}
+
+// do sends c, validates the response against the spec and reports the
+// outcome. It returns nil if the request could not be made.
+func (r *runner) do(c call) *result {
+	label := c.method + " " + strings.TrimPrefix(strings.TrimPrefix(c.url, r.base), r.admin)
+
+	var body io.Reader
+	if c.body != nil {
+		data, err := json.Marshal(c.body)
+		if err != nil {
+			r.failf("%s: %v", label, err)
+			return nil
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(c.method, c.url, body)
+	if err != nil {
+		r.failf("%s: %v", label, err)
+		return nil
+	}
+	if c.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range c.header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.failf("%s: %v", label, err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	res := &result{status: resp.StatusCode, header: resp.Header}
+	streaming := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	if streaming {
+		res.events, err = readEvents(resp.Body, c.stream)
+	} else {
+		res.body, err = io.ReadAll(resp.Body)
+	}
+	if err != nil {
+		r.failf("%s: read body: %v", label, err)
+		return nil
+	}
+
+	// Only JSON bodies are checked against their schema
+	checked := res.body
+	if streaming || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		checked = nil
+	}
+	op, err := r.spec.CheckExchange(context.Background(), req, resp, checked)
+	if op != "" {
+		r.covered[op] = true
+	}
+
+	ok := true
+	if err != nil {
+		ok = false
+		r.failf("%s -> %d: %s", label, res.status, firstLine(err))
+	}
+	if c.wantStatus != 0 && res.status != c.wantStatus {
+		ok = false
+		r.failf("%s -> %d, want %d: %s", label, res.status, c.wantStatus, bytes.TrimSpace(res.body))
+	}
+	if c.stream != "" && streaming {
+		if len(res.events) == 0 {
+			ok = false
+			r.failf("%s: stream sent no events", label)
+		}
+		for _, ev := range res.events {
+			if err := r.spec.CheckSchema(c.stream, ev); err != nil {
+				ok = false
+				r.failf("%s: event %s: %s", label, ev, firstLine(err))
+			}
+		}
+	}
+	if ok {
+		fmt.Printf("ok    %-60s %d\n", label, res.status)
+	}
+	return res
+}
+
+// readEvents collects the data payloads of an SSE stream. Status streams never
+// end on their own, so they are read only up to the snapshot event.
+func readEvents(body io.Reader, schema string) ([][]byte, error) {
+	var events [][]byte
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		payload, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		events = append(events, []byte(payload))
+		if schema == "StatusStreamEvent" || payload == `{"type":"done"}` {
+			break
+		}
+	}
+	return events, scanner.Err()
+}
+
+func (r *runner) failf(format string, args ...interface{}) {
+	r.failures++
+	fmt.Printf("FAIL  "+format+"\n", args...)
+}
+
+// mintToken signs a short-lived HS256 token for the demo owner, like
+// cmd/devtoken
+func mintToken(cfg config.Auth) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": store.DemoOwnerID,
+		"iat": now.Unix(),
+		"exp": now.Add(10 * time.Minute).Unix(),
+	}
+	claims[cfg.OwnerClaim] = store.DemoOwnerID
+	if cfg.Issuer != "" {
+		claims["iss"] = cfg.Issuer
+	}
+	if cfg.Audience != "" {
+		claims["aud"] = cfg.Audience
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.HS256Secret))
+}
+
+func merge(a, b map[string]string) map[string]string {
+	out := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
+
+func firstLine(err error) string {
+	msg, _, _ := strings.Cut(err.Error(), "\n")
+	return msg
+}
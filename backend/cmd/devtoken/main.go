@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"refund-demo/internal/config"
+	"refund-demo/internal/store"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Mints an HS256 bearer token for local development against JWT_HS256_SECRET
+func main() {
+	// Setup logging
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	secret := flag.String("secret", "", "HS256 signing secret (or use JWT_HS256_SECRET env)")
+	owner := flag.String("owner", store.DemoOwnerID, "Owner claim the token grants access to")
+	ttl := flag.Duration("ttl", time.Hour, "Token lifetime")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	if *secret == "" {
+		*secret = cfg.Auth.HS256Secret
+	}
+	if *secret == "" {
+		log.Fatal().Msg("a secret is required (-secret or JWT_HS256_SECRET)")
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": *owner,
+		"iat": now.Unix(),
+		"exp": now.Add(*ttl).Unix(),
+	}
+	claims[cfg.Auth.OwnerClaim] = *owner
+	if cfg.Auth.Issuer != "" {
+		claims["iss"] = cfg.Auth.Issuer
+	}
+	if cfg.Auth.Audience != "" {
+		claims["aud"] = cfg.Auth.Audience
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(*secret))
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to sign token")
+	}
+	fmt.Println(token)
+}
@@ -1,64 +1,295 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
 	"refund-demo/internal/api"
+	"refund-demo/internal/auth"
+	"refund-demo/internal/config"
+	"refund-demo/internal/health"
+	"refund-demo/internal/logging"
+	"refund-demo/internal/metrics"
+	"refund-demo/internal/openapi"
+	"refund-demo/internal/outbox"
+	"refund-demo/internal/ratelimit"
 	"refund-demo/internal/scraper"
 	"refund-demo/internal/store"
+	"refund-demo/internal/tracing"
+	"refund-demo/internal/webhook"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
-	// Setup structured logging
+	// Setup structured logging; replaced by the configured format once loaded
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
+	// Load and validate configuration
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatal().Err(err).Msg("failed to configure logging")
+	}
+	logEffectiveConfig(cfg)
+
+	// Install the tracer provider before anything creates spans
+	shutdownTracing, err := tracing.Setup(cfg.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to configure tracing")
+	}
+
+	// Filing seasons and the business calendar shape every ETA and explanation
+	eta, err := store.NewETAEngine(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load ETA calendars")
+	}
+	log.Info().Ints("tax_years", eta.Seasons.TaxYears()).Msg("filing season calendar loaded")
+
 	// Initialize database
-	db := store.InitPostgres()
-	defer db.Close()
+	db, migrations := store.InitPostgres(cfg.Database, cfg.DemoMode, eta)
+	if err := store.LoadCalibration(context.Background(), db, eta); err != nil {
+		log.Fatal().Err(err).Msg("failed to load confidence calibration")
+	}
+
+	// Listen for status changes so SSE subscribers on every replica get pushed updates
+	broker, err := store.NewStatusBroker(cfg.Database.DSN)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to start status listener")
+	}
+
+	// Configure bearer token authentication for the /v1 API
+	var verifier *auth.Verifier
+	if cfg.Auth.Disabled {
+		log.Warn().Msg("auth disabled - /v1 endpoints are unauthenticated")
+	} else {
+		verifier, err = auth.NewVerifier(cfg.Auth)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to configure authentication (set JWT_HS256_SECRET or JWT_JWKS_FILE, or AUTH_DISABLED=true for local demos)")
+		}
+	}
+
+	// Select rate limit store: per-replica memory (default), shared postgres, or off
+	var limiter ratelimit.Store
+	switch cfg.RateLimit.Store {
+	case "memory":
+		limiter = ratelimit.NewMemoryStore()
+	case "postgres":
+		limiter = ratelimit.NewPostgresStore(db.DB)
+	case "off":
+		log.Warn().Msg("rate limiting disabled")
+	}
+
+	// The embedded contract is served to clients and, when enabled, checked
+	// against live traffic
+	spec, err := openapi.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load OpenAPI spec")
+	}
 
 	// Create Fiber app
-	app := fiber.New(fiber.Config{
-		ServerHeader: "TurboTax Refund Demo",
-		AppName:      "refund-demo v1.0",
-	})
+	app := fiber.New(api.AppConfig("refund-demo v1.0", cfg.Server))
 
 	// Middleware
-	app.Use(logger.New())
+	app.Use(tracing.Middleware())
+	app.Use(logging.Middleware())
+	app.Use(metrics.Middleware())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins:     "http://localhost:3000",
-		AllowHeaders:     "Origin, Content-Type, Accept",
+		AllowOrigins:     strings.Join(cfg.Server.CORSOrigins, ", "),
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Traceparent, Tracestate, X-Request-ID, If-Match, If-None-Match, If-Modified-Since",
+		ExposeHeaders:    "X-Request-ID, ETag, Last-Modified, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After",
 		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
 		AllowCredentials: true,
 	}))
+	app.Use(spec.Middleware(cfg.OpenAPI.Validation))
+	app.Get("/openapi.json", spec.JSONHandler())
+	app.Get("/docs", openapi.DocsHandler())
+
+	// Drainer flips readiness and winds down streams on shutdown
+	drainer := api.NewDrainer()
 
-	// Health check endpoint
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status": "healthy",
-			"service": "refund-demo",
-		})
-	})
+	scheduler, err := scraper.NewScheduler(db, cfg, eta)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to schedule background jobs")
+	}
+
+	// Liveness has no dependency checks so an outage doesn't restart every
+	// replica; readiness takes the replica out of rotation instead
+	liveness := health.NewChecker(cfg.Health.CacheTTL, cfg.Health.CheckTimeout)
+	readiness := health.NewChecker(cfg.Health.CacheTTL, cfg.Health.CheckTimeout,
+		health.DatabaseCheck(db),
+		health.MigrationCheck(db, migrations),
+		health.SchedulerCheck(scheduler),
+		health.LLMCheck(cfg.LLM),
+	)
+	app.Get("/livez", api.LivezHandler(liveness))
+	app.Get("/readyz", api.ReadyzHandler(readiness, drainer))
+	// Kept for existing clients with its original liveness-style contract
+	app.Get("/health", api.HealthHandler())
 
 	// Register API routes
-	api.RegisterRoutes(app, db)
+	api.RegisterRoutes(app, db, cfg, broker, verifier, limiter, drainer, eta)
+
+	// Export pool stats and returns-by-status alongside the request metrics
+	metrics.RegisterDB(db)
+
+	// Serve admin routes and /metrics on a separate listener when an admin port
+	// is set, so they can be kept off the public network; otherwise mount them
+	// on the main app
+	servers := []*server{{name: "Server", app: app, port: cfg.Server.Port}}
+	if cfg.Server.AdminPort != 0 {
+		adminApp := fiber.New(api.AppConfig("refund-demo admin", cfg.Server))
+		adminApp.Use(tracing.Middleware())
+		adminApp.Use(logging.Middleware())
+		adminApp.Use(metrics.Middleware())
+		adminApp.Use(spec.Middleware(cfg.OpenAPI.Validation))
+		adminApp.Get("/metrics", metrics.Handler())
+		api.RegisterInternalRoutes(adminApp, db, eta)
+		servers = append(servers, &server{name: "Admin server", app: adminApp, port: cfg.Server.AdminPort})
+	} else {
+		app.Get("/metrics", metrics.Handler())
+		api.RegisterInternalRoutes(app, db, eta)
+	}
+
+	// Routes missing from the spec are drift; strict mode refuses to start
+	if cfg.OpenAPI.Validation != openapi.ModeOff {
+		for _, srv := range servers {
+			if missing := spec.Undocumented(srv.app); len(missing) > 0 {
+				event := log.Warn()
+				if cfg.OpenAPI.Validation == openapi.ModeStrict {
+					event = log.Fatal()
+				}
+				event.Strs("routes", missing).Msg(srv.name + " has routes missing from openapi.yaml")
+			}
+		}
+	}
+
+	// Start background scraper and calibration jobs
+	scheduler.Start()
+
+	// Start webhook dispatcher
+	dispatcher := webhook.NewDispatcher(db)
+	dispatcher.Start()
+
+	// Start outbox relay if a sink is configured
+	sink, err := outbox.NewSink(cfg.Outbox)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid outbox sink configuration")
+	}
+	var relay *outbox.Relay
+	if sink != nil {
+		relay = outbox.NewRelay(db, sink)
+		relay.Start()
+	}
+
+	// Serve until SIGINT/SIGTERM or a listener fails
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, len(servers))
+	for _, srv := range servers {
+		srv := srv
+		go func() {
+			log.Info().Int("port", srv.port).Msg(srv.name + " starting")
+			if err := srv.app.Listen(":" + strconv.Itoa(srv.port)); err != nil {
+				listenErr <- fmt.Errorf("%s: %w", strings.ToLower(srv.name), err)
+			}
+		}()
+	}
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		log.Info().Msg("Shutdown signal received, draining (send again to force exit)")
+	case err := <-listenErr:
+		log.Error().Err(err).Msg("Server stopped unexpectedly, shutting down")
+		exitCode = 1
+	}
+	// A second signal falls through to the default handler and exits immediately
+	stop()
+
+	// Fail readiness first, then give load balancers time to notice
+	drainer.Begin()
+	time.Sleep(cfg.Server.DrainDelay)
 
-	// Start background scraper job
-	go scraper.StartBackgroundJob(db)
+	// Stop accepting connections; in-flight requests and streams keep running
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		for _, srv := range servers {
+			if err := srv.app.ShutdownWithTimeout(cfg.Server.ShutdownTimeout + streamAbortGrace); err != nil {
+				log.Warn().Err(err).Msg(srv.name + " did not shut down cleanly")
+			}
+		}
+	}()
+
+	// Let explain streams finish, then tell stragglers to send a terminal event
+	if !drainer.Wait(cfg.Server.ShutdownTimeout) {
+		log.Warn().Dur("timeout", cfg.Server.ShutdownTimeout).Msg("streams still open at shutdown deadline, interrupting")
+		drainer.Abort()
+		drainer.Wait(streamAbortGrace)
+	}
+	<-shutdownDone
+
+	// Stop background work before the pool it depends on
+	scheduler.Stop()
+	dispatcher.Stop()
+	if relay != nil {
+		relay.Stop()
+	}
+	broker.Close()
+	if err := db.Close(); err != nil {
+		log.Error().Err(err).Msg("failed to close database pool")
+	}
+
+	// Flush spans last so the shutdown itself is traced
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Error().Err(err).Msg("failed to flush traces")
+	}
+	cancelFlush()
+
+	log.Info().Msg("Shutdown complete")
+	os.Exit(exitCode)
+}
+
+// streamAbortGrace is how long interrupted streams get to write their
+// terminal event after the shutdown deadline
+const streamAbortGrace = 2 * time.Second
+
+// server is a Fiber app and the port it listens on
+type server struct {
+	name string
+	app  *fiber.App
+	port int
+}
 
-	// Get port from environment or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+// logEffectiveConfig prints the resolved configuration with secrets redacted
+func logEffectiveConfig(cfg *config.Config) {
+	redacted := cfg.Redacted()
+	keys := make([]string, 0, len(redacted))
+	for k := range redacted {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	log.Info().Str("port", port).Msg("Server starting")
-	if err := app.Listen(":" + port); err != nil {
-		log.Fatal().Err(err).Msg("Failed to start server")
+	event := log.Info()
+	for _, k := range keys {
+		event = event.Str(k, redacted[k])
 	}
+	event.Msg("effective configuration")
 }
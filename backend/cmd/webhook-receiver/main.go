@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+
+	"refund-demo/internal/webhook"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// A local webhook receiver for testing the dispatcher end to end. It verifies
+// signatures and logs each event. Use -fail to simulate a broken subscriber and
+// exercise retries and the dead-letter state.
+func main() {
+	// Setup logging
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	addr := flag.String("addr", ":9090", "Address to listen on")
+	secret := flag.String("secret", "", "Subscription secret used to verify signatures (or use WEBHOOK_SECRET env)")
+	fail := flag.Bool("fail", false, "Respond 500 to every request")
+	flag.Parse()
+
+	if *secret == "" {
+		*secret = os.Getenv("WEBHOOK_SECRET")
+	}
+	if *secret == "" {
+		log.Warn().Msg("no secret configured, signatures will not be verified")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		if *secret != "" {
+			err := webhook.Verify(*secret, r.Header.Get(webhook.HeaderSignature),
+				r.Header.Get(webhook.HeaderTimestamp), body, webhook.DefaultTolerance)
+			if err != nil {
+				log.Warn().Err(err).Str("delivery_id", r.Header.Get(webhook.HeaderDeliveryID)).Msg("rejected webhook")
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		if *fail {
+			log.Warn().Str("delivery_id", r.Header.Get(webhook.HeaderDeliveryID)).Msg("simulating failure")
+			http.Error(w, "simulated failure", http.StatusInternalServerError)
+			return
+		}
+
+		var event map[string]interface{}
+		json.Unmarshal(body, &event)
+		log.Info().
+			Str("delivery_id", r.Header.Get(webhook.HeaderDeliveryID)).
+			Str("event", r.Header.Get(webhook.HeaderEvent)).
+			Interface("payload", event).
+			Msg("received webhook")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Info().Str("addr", *addr).Msg("webhook receiver listening")
+	if err := http.ListenAndServe(*addr, nil); err != nil {
+		log.Fatal().Err(err).Msg("receiver stopped")
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"refund-demo/internal/logging"
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateAdjustmentRequest struct {
+	Kind        string `json:"kind"`
+	AmountCents int64  `json:"amount_cents"`
+	// Currency defaults to the return's currency
+	Currency    string     `json:"currency"`
+	ReasonCode  string     `json:"reason_code"`
+	Agency      string     `json:"agency"`
+	Description string     `json:"description"`
+	OccurredAt  *time.Time `json:"occurred_at"`
+}
+
+// CreateAdjustmentHandler records an offset, correction, interest or partial
+// payment against a return. Like a transition it bumps the return's version,
+// and an If-Match header makes it conditional on the version the caller saw.
+func CreateAdjustmentHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req CreateAdjustmentRequest
+		if err := c.BodyParser(&req); err != nil {
+			return sendError(c, 400, CodeBadRequest, "invalid request body")
+		}
+
+		expectedVersion, err := parseIfMatch(c.Get(fiber.HeaderIfMatch))
+		if err != nil {
+			return sendError(c, 400, CodeBadRequest, err.Error())
+		}
+
+		withReturnID(c, c.Params("id"))
+		adj := store.Adjustment{
+			ReturnID:    c.Params("id"),
+			Kind:        req.Kind,
+			AmountCents: req.AmountCents,
+			Currency:    req.Currency,
+			ReasonCode:  req.ReasonCode,
+			Agency:      req.Agency,
+			Description: req.Description,
+		}
+		if req.OccurredAt != nil {
+			adj.OccurredAt = req.OccurredAt.UTC()
+		}
+
+		r, err := store.RecordAdjustment(c.UserContext(), db, &adj, expectedVersion)
+		switch {
+		case errors.Is(err, store.ErrInvalidAdjustment):
+			return sendError(c, 400, CodeBadRequest, err.Error())
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, 404, CodeNotFound, "not found")
+		case errors.Is(err, store.ErrConflict) && expectedVersion != 0:
+			return sendError(c, 412, CodePreconditionFailed, "return has been modified; reload and retry")
+		case errors.Is(err, store.ErrConflict):
+			return sendError(c, 409, CodeConflict, err.Error())
+		case err != nil:
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to record adjustment")
+			return sendError(c, 500, CodeInternal, "failed to record adjustment")
+		}
+
+		c.Set(fiber.HeaderETag, versionETag(r.Version))
+		return c.Status(201).JSON(adj)
+	}
+}
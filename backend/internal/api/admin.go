@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"refund-demo/internal/auth"
+	"refund-demo/internal/logging"
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const apiKeyLocalsKey = "api_key"
+
+// auditWriteTimeout bounds the audit insert, which outlives the request
+const auditWriteTimeout = 5 * time.Second
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+var knownScopes = map[string]bool{
+	store.ScopeAdmin:         true,
+	store.ScopeReturnsRead:   true,
+	store.ScopeReturnsWrite:  true,
+	store.ScopeWebhooksRead:  true,
+	store.ScopeWebhooksWrite: true,
+}
+
+// AuditAdminActions records every request that reaches the admin routes,
+// including ones rejected for missing or insufficient credentials
+func AuditAdminActions(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The app error handler hasn't run yet, so derive the status it will send
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+
+		rec := store.AuditRecord{
+			Action:     c.Method() + " " + c.Route().Path,
+			Method:     c.Method(),
+			Path:       c.Path(),
+			StatusCode: status,
+		}
+		if ip := c.IP(); ip != "" {
+			rec.RemoteIP = &ip
+		}
+		if key, ok := c.Locals(apiKeyLocalsKey).(*store.APIKey); ok {
+			rec.KeyID = &key.KeyID
+		}
+		// The action has already run, so record it even if the client has
+		// gone; the detached context keeps the request's logger and trace
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.UserContext()), auditWriteTimeout)
+		defer cancel()
+		if auditErr := store.InsertAuditRecord(ctx, db, rec); auditErr != nil {
+			logging.Ctx(ctx).Error().Err(auditErr).Str("action", rec.Action).Msg("failed to write admin audit record")
+		}
+		return err
+	}
+}
+
+// RequireAPIKey authenticates admin callers by an API key sent in X-API-Key
+// or as a bearer token
+func RequireAPIKey(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		raw := c.Get("X-API-Key")
+		if raw == "" {
+			raw, _ = auth.BearerToken(c.Get(fiber.HeaderAuthorization))
+		}
+		if raw == "" {
+			return sendError(c, 401, CodeUnauthorized, "missing API key")
+		}
+
+		key, err := store.FindActiveAPIKey(c.UserContext(), db, raw)
+		if errors.Is(err, sql.ErrNoRows) {
+			return sendError(c, 401, CodeUnauthorized, "invalid or revoked API key")
+		}
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to look up API key")
+			return sendError(c, 500, CodeInternal, "failed to authenticate")
+		}
+
+		c.Locals(apiKeyLocalsKey, key)
+		return c.Next()
+	}
+}
+
+// RequireScope rejects API keys that don't grant scope
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, ok := c.Locals(apiKeyLocalsKey).(*store.APIKey)
+		if !ok || !key.HasScope(scope) {
+			return sendError(c, 403, CodeForbidden, "API key lacks required scope "+scope)
+		}
+		return c.Next()
+	}
+}
+
+// CreateAPIKeyHandler issues a new key; the plaintext is only returned here
+func CreateAPIKeyHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req CreateAPIKeyRequest
+		if err := c.BodyParser(&req); err != nil {
+			return sendError(c, 400, CodeBadRequest, "invalid request body")
+		}
+		if strings.TrimSpace(req.Name) == "" || len(req.Scopes) == 0 {
+			return sendError(c, 400, CodeBadRequest, "name and at least one scope are required")
+		}
+		for _, s := range req.Scopes {
+			if !knownScopes[s] {
+				return sendError(c, 400, CodeBadRequest, "unknown scope "+s)
+			}
+		}
+
+		raw, key, err := store.CreateAPIKey(c.UserContext(), db, req.Name, req.Scopes)
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to create API key")
+			return sendError(c, 500, CodeInternal, "failed to create API key")
+		}
+		return c.Status(201).JSON(fiber.Map{
+			"key":     key,
+			"api_key": raw,
+		})
+	}
+}
+
+func ListAPIKeysHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		keys, err := store.ListAPIKeys(c.UserContext(), db)
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to list API keys")
+			return sendError(c, 500, CodeInternal, "failed to list API keys")
+		}
+		return c.JSON(keys)
+	}
+}
+
+func RevokeAPIKeyHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		revoked, err := store.RevokeAPIKey(c.UserContext(), db, c.Params("id"))
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to revoke API key")
+			return sendError(c, 500, CodeInternal, "failed to revoke API key")
+		}
+		if !revoked {
+			return sendError(c, 404, CodeNotFound, "no active key with that id")
+		}
+		return c.SendStatus(204)
+	}
+}
+
+// ListAuditLogHandler returns recent admin audit records
+func ListAuditLogHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := c.QueryInt("limit", 100)
+		if limit <= 0 || limit > 1000 {
+			limit = 100
+		}
+		records, err := store.ListAuditRecords(c.UserContext(), db, limit)
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to list audit records")
+			return sendError(c, 500, CodeInternal, "failed to list audit records")
+		}
+		return c.JSON(records)
+	}
+}
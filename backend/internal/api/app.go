@@ -0,0 +1,25 @@
+package api
+
+import (
+	"refund-demo/internal/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AppConfig is the Fiber configuration for the public and admin apps. Behind
+// one of server's trusted proxies, c.IP() is the first valid address in
+// X-Forwarded-For, so rate limits and audit records see the client rather
+// than the proxy; from anywhere else the header is ignored.
+func AppConfig(appName string, server config.Server) fiber.Config {
+	cfg := fiber.Config{
+		ServerHeader: "TurboTax Refund Demo",
+		AppName:      appName,
+	}
+	if len(server.TrustedProxies) > 0 {
+		cfg.ProxyHeader = fiber.HeaderXForwardedFor
+		cfg.EnableTrustedProxyCheck = true
+		cfg.TrustedProxies = server.TrustedProxies
+		cfg.EnableIPValidation = true
+	}
+	return cfg
+}
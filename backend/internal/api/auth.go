@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+
+	"refund-demo/internal/auth"
+	"refund-demo/internal/logging"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const principalKey = "principal"
+
+// RequireAuth authenticates bearer tokens on every request and stores the
+// principal for handlers. A nil verifier disables authentication entirely,
+// which is only intended for local demos.
+func RequireAuth(verifier *auth.Verifier) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if verifier == nil {
+			return c.Next()
+		}
+
+		token, ok := auth.BearerToken(c.Get(fiber.HeaderAuthorization))
+		if !ok {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="refund-demo"`)
+			return sendError(c, 401, CodeUnauthorized, "missing bearer token")
+		}
+
+		principal, err := verifier.Verify(token)
+		if err != nil {
+			logging.Ctx(c.UserContext()).Debug().Err(err).Msg("rejected bearer token")
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="refund-demo", error="invalid_token"`)
+			msg := "invalid or expired token"
+			if errors.Is(err, auth.ErrMissingOwner) {
+				msg = err.Error()
+			}
+			return sendError(c, 401, CodeUnauthorized, msg)
+		}
+
+		c.Locals(principalKey, principal)
+		return c.Next()
+	}
+}
+
+// principalFrom returns the authenticated caller, or nil when auth is disabled
+func principalFrom(c *fiber.Ctx) *auth.Principal {
+	p, _ := c.Locals(principalKey).(*auth.Principal)
+	return p
+}
+
+// canAccessReturn reports whether the caller owns a return with the given owner.
+// Returns without an owner are not accessible to any authenticated principal.
+func canAccessReturn(c *fiber.Ctx, ownerID *string) bool {
+	p := principalFrom(c)
+	if p == nil {
+		return true
+	}
+	return ownerID != nil && *ownerID == p.Owner
+}
+
+// sendInaccessible answers a request for a return or filing the caller
+// doesn't own with the same 404 as an unknown ID, so IDs can't be probed. The
+// denial is logged for investigation.
+func sendInaccessible(c *fiber.Ctx) error {
+	event := logging.Ctx(c.UserContext()).Warn().Str("path", c.Path())
+	if p := principalFrom(c); p != nil {
+		event = event.Str("subject", p.Subject)
+	}
+	event.Msg("denied access to a return owned by someone else")
+	return sendError(c, 404, CodeNotFound, "not found")
+}
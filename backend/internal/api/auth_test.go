@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"refund-demo/internal/auth"
+	"refund-demo/internal/config"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newAuthApp serves /v1/status/:id behind RequireAuth with the ownership
+// check StatusHandler makes, for a return owned by owner
+func newAuthApp(t *testing.T, verifier *auth.Verifier, owner *string) *fiber.App {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/v1/status/:id", RequireAuth(verifier), func(c *fiber.Ctx) error {
+		if !canAccessReturn(c, owner) {
+			return sendInaccessible(c)
+		}
+		return c.JSON(fiber.Map{"return_id": c.Params("id")})
+	})
+	return app
+}
+
+func token(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestStatusAccess(t *testing.T) {
+	verifier, err := auth.NewVerifier(config.Auth{HS256Secret: "test-secret"})
+	if err != nil {
+		t.Fatalf("NewVerifier() = %v", err)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	owner := "owner-1"
+
+	tests := []struct {
+		name          string
+		verifier      *auth.Verifier
+		owner         *string
+		authorization string
+		want          int
+		challenge     bool
+	}{
+		{"owner", verifier, &owner, "Bearer " + token(t, jwt.MapClaims{"sub": owner, "exp": exp}), 200, false},
+		{"non-owner", verifier, &owner, "Bearer " + token(t, jwt.MapClaims{"sub": "owner-2", "exp": exp}), 404, false},
+		{"return without an owner", verifier, nil, "Bearer " + token(t, jwt.MapClaims{"sub": owner, "exp": exp}), 404, false},
+		{"no token", verifier, &owner, "", 401, true},
+		{"not a bearer token", verifier, &owner, "Basic b3duZXItMTpwdw==", 401, true},
+		{"expired token", verifier, &owner, "Bearer " + token(t, jwt.MapClaims{"sub": owner, "exp": time.Now().Add(-time.Minute).Unix()}), 401, true},
+		{"token without an owner", verifier, &owner, "Bearer " + token(t, jwt.MapClaims{"exp": exp}), 401, true},
+		{"auth disabled", nil, &owner, "", 200, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v1/status/01HZDEM0001AAAAAAAAAAAAAAA", nil)
+		if tt.authorization != "" {
+			req.Header.Set(fiber.HeaderAuthorization, tt.authorization)
+		}
+		resp, err := newAuthApp(t, tt.verifier, tt.owner).Test(req)
+		if err != nil {
+			t.Fatalf("app.Test() = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+		if got := resp.Header.Get(fiber.HeaderWWWAuthenticate) != ""; got != tt.challenge {
+			t.Errorf("%s: WWW-Authenticate present = %v, want %v", tt.name, got, tt.challenge)
+		}
+	}
+}
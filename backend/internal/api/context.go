@@ -0,0 +1,13 @@
+package api
+
+import (
+	"refund-demo/internal/logging"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// withReturnID adds return_id to the request logger so handler and store
+// log lines for this request can be filtered by return
+func withReturnID(c *fiber.Ctx, returnID string) {
+	c.SetUserContext(logging.WithReturnID(c.UserContext(), returnID))
+}
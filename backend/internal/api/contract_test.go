@@ -0,0 +1,301 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"refund-demo/internal/auth"
+	"refund-demo/internal/config"
+	"refund-demo/internal/health"
+	"refund-demo/internal/metrics"
+	"refund-demo/internal/openapi"
+	"refund-demo/internal/ratelimit"
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// contractSecret signs the bearer tokens the contract tests send
+const contractSecret = "contract-test-secret"
+
+// contractSuite drives an app wired like cmd/server, with strict OpenAPI
+// validation, and records which operations each response matched
+type contractSuite struct {
+	t       *testing.T
+	app     *fiber.App
+	spec    *openapi.Spec
+	covered map[string]bool
+}
+
+// contractCall is one request in a suite
+type contractCall struct {
+	method string
+	path   string
+	body   interface{}
+	header map[string]string
+	want   int
+}
+
+// newContractSuite mounts every public, /v1 and /internal route on one app.
+// db and broker may be nil for tests that never reach the database.
+func newContractSuite(t *testing.T, db *store.DB, broker *store.StatusBroker) *contractSuite {
+	t.Helper()
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("openapi.Load() = %v", err)
+	}
+	cfg := config.Default()
+	eta, err := store.NewETAEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewETAEngine() = %v", err)
+	}
+	verifier, err := auth.NewVerifier(config.Auth{HS256Secret: contractSecret, OwnerClaim: "sub"})
+	if err != nil {
+		t.Fatalf("NewVerifier() = %v", err)
+	}
+
+	stub := health.Check{Name: "stub", Run: func(context.Context) health.Result { return health.OK(nil) }}
+	checker := health.NewChecker(time.Second, time.Second, stub)
+	drainer := NewDrainer()
+
+	app := fiber.New()
+	app.Use(spec.Middleware(openapi.ModeStrict))
+	app.Get("/openapi.json", spec.JSONHandler())
+	app.Get("/docs", openapi.DocsHandler())
+	app.Get("/livez", LivezHandler(health.NewChecker(time.Second, time.Second)))
+	app.Get("/readyz", ReadyzHandler(checker, drainer))
+	app.Get("/health", HealthHandler())
+	app.Get("/metrics", metrics.Handler())
+	RegisterRoutes(app, db, cfg, broker, verifier, ratelimit.NewMemoryStore(), drainer, eta)
+	RegisterInternalRoutes(app, db, eta)
+
+	return &contractSuite{t: t, app: app, spec: spec, covered: map[string]bool{}}
+}
+
+// do sends c and fails the test if the status differs from c.want or the
+// response doesn't match the spec. Strict validation has already replaced
+// a non-conforming response with a 500; the exchange is checked again here
+// so the operation is recorded as covered.
+func (s *contractSuite) do(c contractCall) (*http.Response, []byte) {
+	s.t.Helper()
+	label := c.method + " " + c.path
+
+	var body io.Reader
+	if c.body != nil {
+		data, err := json.Marshal(c.body)
+		if err != nil {
+			s.t.Fatalf("%s: %v", label, err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(c.method, c.path, body)
+	if c.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range c.header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		s.t.Fatalf("%s: %v", label, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatalf("%s: read body: %v", label, err)
+	}
+
+	if resp.StatusCode != c.want {
+		s.t.Errorf("%s -> %d, want %d: %s", label, resp.StatusCode, c.want, bytes.TrimSpace(data))
+	}
+	checked := data
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), fiber.MIMEApplicationJSON) {
+		checked = nil
+	}
+	op, err := s.spec.CheckExchange(context.Background(), req, resp, checked)
+	if err != nil {
+		msg, _, _ := strings.Cut(err.Error(), "\n")
+		s.t.Errorf("%s -> %d does not match the contract: %s", label, resp.StatusCode, msg)
+	}
+	if op != "" {
+		s.covered[op] = true
+	}
+	return resp, data
+}
+
+func bearer(t *testing.T, owner string) map[string]string {
+	t.Helper()
+	now := time.Now()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": owner,
+		"iat": now.Unix(),
+		"exp": now.Add(10 * time.Minute).Unix(),
+	}).SignedString([]byte(contractSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+func TestContractRoutesAreDocumented(t *testing.T) {
+	s := newContractSuite(t, nil, nil)
+	if missing := s.spec.Undocumented(s.app); len(missing) > 0 {
+		t.Fatalf("routes missing from openapi.yaml: %v", missing)
+	}
+}
+
+func TestContractWithoutDatabase(t *testing.T) {
+	s := newContractSuite(t, nil, nil)
+	id := store.NewULID()
+	invalid := map[string]string{"Authorization": "Bearer not-a-token"}
+
+	s.do(contractCall{method: "GET", path: "/livez", want: 200})
+	s.do(contractCall{method: "GET", path: "/readyz", want: 200})
+	s.do(contractCall{method: "GET", path: "/health", want: 200})
+	s.do(contractCall{method: "GET", path: "/openapi.json", want: 200})
+	s.do(contractCall{method: "GET", path: "/docs", want: 200})
+	s.do(contractCall{method: "GET", path: "/metrics", want: 200})
+
+	s.do(contractCall{method: "GET", path: "/v1/status/" + id, want: 401})
+	s.do(contractCall{method: "GET", path: "/v1/status/" + id, header: invalid, want: 401})
+	s.do(contractCall{method: "GET", path: "/v1/status/" + id + "/stream", want: 401})
+	s.do(contractCall{method: "GET", path: "/v1/filings/" + id, want: 401})
+	s.do(contractCall{method: "POST", path: "/v1/status/explain", body: map[string]string{"return_id": id}, want: 401})
+	s.do(contractCall{method: "POST", path: "/v1/status/explain", body: map[string]int{"return_id": 7}, header: bearer(t, store.DemoOwnerID), want: 400})
+}
+
+// TestContractWithDatabase exercises every operation, including the admin
+// routes, against a real database. Set TEST_DATABASE_URL to a scratch
+// database; it is migrated and seeded.
+func TestContractWithDatabase(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	dbCfg := config.Default().Database
+	dbCfg.DSN = dsn
+	dbCfg.MigrationsPath = "file://../../migrations"
+	eta, err := store.NewETAEngine(config.Default())
+	if err != nil {
+		t.Fatalf("NewETAEngine() = %v", err)
+	}
+	db, _ := store.InitPostgres(dbCfg, true, eta)
+	defer db.Close()
+	broker, err := store.NewStatusBroker(dsn)
+	if err != nil {
+		t.Fatalf("NewStatusBroker() = %v", err)
+	}
+	defer broker.Close()
+
+	rawKey, _, err := store.CreateAPIKey(context.Background(), db, "contract-test", []string{store.ScopeAdmin})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() = %v", err)
+	}
+	admin := map[string]string{"X-API-Key": rawKey}
+	owner := bearer(t, store.DemoOwnerID)
+	s := newContractSuite(t, db, broker)
+
+	s.do(contractCall{method: "GET", path: "/livez", want: 200})
+	s.do(contractCall{method: "GET", path: "/readyz", want: 200})
+	s.do(contractCall{method: "GET", path: "/health", want: 200})
+	s.do(contractCall{method: "GET", path: "/openapi.json", want: 200})
+	s.do(contractCall{method: "GET", path: "/docs", want: 200})
+	s.do(contractCall{method: "GET", path: "/metrics", want: 200})
+
+	_, data := s.do(contractCall{method: "POST", path: "/internal/scrape", header: admin, want: 200})
+	var created struct {
+		ReturnID string `json:"return_id"`
+	}
+	if err := json.Unmarshal(data, &created); err != nil || created.ReturnID == "" {
+		t.Fatalf("scrape returned %s (%v), want a return_id", data, err)
+	}
+	id := created.ReturnID
+
+	resp, data := s.do(contractCall{method: "GET", path: "/v1/status/" + id, header: owner, want: 200})
+	etag := resp.Header.Get("ETag")
+	var current struct {
+		FilingID string `json:"filing_id"`
+	}
+	json.Unmarshal(data, &current)
+	s.do(contractCall{method: "GET", path: "/v1/status/" + id, header: merge(owner, map[string]string{"If-None-Match": etag}), want: 304})
+	s.do(contractCall{method: "GET", path: "/v1/status/" + id, header: bearer(t, "someone-else"), want: 404})
+	s.do(contractCall{method: "GET", path: "/v1/status/" + store.NewULID(), header: owner, want: 404})
+	s.do(contractCall{method: "GET", path: "/v1/filings/" + current.FilingID, header: owner, want: 200})
+	s.do(contractCall{method: "GET", path: "/v1/filings/" + store.NewULID(), header: owner, want: 404})
+	// A live stream never ends, so only its rejections are exercised here;
+	// cmd/contract checks the events against a running server
+	s.do(contractCall{method: "GET", path: "/v1/status/" + store.NewULID() + "/stream", header: owner, want: 404})
+	s.do(contractCall{method: "POST", path: "/v1/status/explain", header: owner, want: 200,
+		body: map[string]string{"return_id": id, "question": "Why is my refund delayed?"}})
+
+	s.do(contractCall{method: "POST", path: "/internal/returns/" + id + "/transition", want: 200,
+		header: merge(admin, map[string]string{"If-Match": etag}), body: map[string]string{"status": store.StatusSent}})
+	s.do(contractCall{method: "POST", path: "/internal/returns/" + id + "/transition", want: 412,
+		header: merge(admin, map[string]string{"If-Match": etag}), body: map[string]string{"status": store.StatusCompleted}})
+	offset := map[string]interface{}{"kind": store.AdjustmentOffset, "amount_cents": -12500,
+		"reason_code": store.ReasonFederalDebt, "agency": "U.S. Department of Education"}
+	s.do(contractCall{method: "POST", path: "/internal/returns/" + id + "/adjustments", header: admin, body: offset, want: 201})
+	s.do(contractCall{method: "POST", path: "/internal/returns/" + id + "/adjustments", header: admin, want: 400,
+		body: map[string]interface{}{"kind": store.AdjustmentOffset, "amount_cents": 12500}})
+	s.do(contractCall{method: "GET", path: "/v1/status/" + id, header: owner, want: 200})
+
+	_, data = s.do(contractCall{method: "POST", path: "/internal/webhooks", header: admin, want: 201,
+		body: map[string]interface{}{"url": "https://example.com/hooks/contract", "events": []string{}}})
+	var subscribed struct {
+		Subscription struct {
+			SubscriptionID string `json:"subscription_id"`
+		} `json:"subscription"`
+	}
+	json.Unmarshal(data, &subscribed)
+	s.do(contractCall{method: "GET", path: "/internal/webhooks", header: admin, want: 200})
+	s.do(contractCall{method: "GET", path: "/internal/webhooks/deliveries?status=DEAD&limit=5", header: admin, want: 200})
+	s.do(contractCall{method: "POST", path: "/internal/webhooks/deliveries/" + store.NewULID() + "/retry", header: admin, want: 404})
+	s.do(contractCall{method: "DELETE", path: "/internal/webhooks/" + subscribed.Subscription.SubscriptionID, header: admin, want: 204})
+
+	_, data = s.do(contractCall{method: "POST", path: "/internal/keys", header: admin, want: 201,
+		body: map[string]interface{}{"name": "contract", "scopes": []string{store.ScopeWebhooksRead}}})
+	var issued struct {
+		Key struct {
+			KeyID string `json:"key_id"`
+		} `json:"key"`
+	}
+	json.Unmarshal(data, &issued)
+	s.do(contractCall{method: "GET", path: "/internal/keys", header: admin, want: 200})
+	s.do(contractCall{method: "DELETE", path: "/internal/keys/" + issued.Key.KeyID, header: admin, want: 204})
+
+	s.do(contractCall{method: "GET", path: "/internal/reports/eta-accuracy?tax_year=2024", header: admin, want: 200})
+	s.do(contractCall{method: "GET", path: "/internal/reports/eta-accuracy?tax_year=soon", header: admin, want: 400})
+	s.do(contractCall{method: "GET", path: "/internal/reports/eta-calibration", header: admin, want: 200})
+	s.do(contractCall{method: "GET", path: "/internal/reports/eta-calibration?format=csv", header: admin, want: 200})
+	s.do(contractCall{method: "GET", path: "/internal/returns/stalled?status=REVIEW&limit=10", header: admin, want: 200})
+	s.do(contractCall{method: "GET", path: "/internal/returns/stalled?status=FILED", header: admin, want: 400})
+	s.do(contractCall{method: "GET", path: "/internal/audit?limit=10", header: admin, want: 200})
+	s.do(contractCall{method: "GET", path: "/internal/audit", want: 401})
+
+	for _, op := range s.spec.Operations() {
+		if !s.covered[op] {
+			t.Errorf("operation %s was not exercised", op)
+		}
+	}
+}
+
+func merge(a, b map[string]string) map[string]string {
+	out := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Drainer coordinates graceful shutdown with the streaming handlers. Once
+// draining starts, readiness fails and new streams are refused; in-flight
+// streams keep running until they finish or Abort tells them to wrap up.
+type Drainer struct {
+	mu       sync.Mutex
+	draining bool
+	streams  sync.WaitGroup
+	drainCh  chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewDrainer creates a drainer in the serving state
+func NewDrainer() *Drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Drainer{
+		drainCh: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Begin switches to draining; it is safe to call more than once
+func (d *Drainer) Begin() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.draining {
+		d.draining = true
+		close(d.drainCh)
+	}
+}
+
+// Draining reports whether shutdown has started
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// Drain is closed when shutdown starts. Open-ended streams such as status
+// subscriptions end here so clients reconnect to another replica.
+func (d *Drainer) Drain() <-chan struct{} {
+	return d.drainCh
+}
+
+// Context is cancelled by Abort; streams pass it to upstream calls and send a
+// terminal event when it is done
+func (d *Drainer) Context() context.Context {
+	return d.ctx
+}
+
+// Abort tells in-flight streams that the shutdown deadline has passed
+func (d *Drainer) Abort() {
+	d.Begin()
+	d.cancel()
+}
+
+// Wait blocks until every tracked stream has finished or timeout elapses and
+// reports whether they all finished
+func (d *Drainer) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		d.streams.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// track registers a stream and returns its release func, or false when the
+// server is draining and the stream should be refused
+func (d *Drainer) track() (func(), bool) {
+	// Registering under the lock keeps Add from racing with Wait
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return nil, false
+	}
+	d.streams.Add(1)
+	return d.streams.Done, true
+}
+
+// sendDraining rejects a request that arrived after shutdown started
+func sendDraining(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderRetryAfter, "1")
+	c.Set(fiber.HeaderConnection, "close")
+	return sendError(c, 503, CodeUnavailable, "server is shutting down, please retry")
+}
@@ -0,0 +1,28 @@
+package api
+
+import "github.com/gofiber/fiber/v2"
+
+// Error codes returned in the "code" field of the error envelope
+const (
+	CodeBadRequest         = "bad_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+	CodeUnavailable        = "service_unavailable"
+)
+
+// ErrorResponse is the envelope for every API error: a human-readable message
+// plus a stable machine-readable code
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// sendError writes the error envelope with the given HTTP status
+func sendError(c *fiber.Ctx, status int, code, message string) error {
+	return c.Status(status).JSON(ErrorResponse{Error: message, Code: code})
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errMalformedIfMatch = errors.New("malformed If-Match header")
+
+// Cache lifetimes for the status endpoint. Completed returns no longer change,
+// so clients can reuse them for a day; in-flight returns must revalidate often.
+const (
+	completedMaxAge = 24 * time.Hour
+	inFlightMaxAge  = 30 * time.Second
+)
+
+// versionETag returns the strong entity tag for a return version
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch extracts the expected version from an If-Match header.
+// It returns 0 when the header is absent or "*", meaning any version.
+func parseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	// Weak validators can't be used for If-Match (RFC 9110 13.1.1)
+	if strings.HasPrefix(header, "W/") || strings.Contains(header, ",") {
+		return 0, errMalformedIfMatch
+	}
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || version <= 0 {
+		return 0, errMalformedIfMatch
+	}
+	return version, nil
+}
+
+// isConditionalGet reports whether the request carries cache validators
+func isConditionalGet(c *fiber.Ctx) bool {
+	return c.Get(fiber.HeaderIfNoneMatch) != "" || c.Get(fiber.HeaderIfModifiedSince) != ""
+}
+
+// notModified evaluates If-None-Match (weak comparison) and, only when that is
+// absent, If-Modified-Since, as required by RFC 9110 13.2.2
+func notModified(c *fiber.Ctx, v *store.ReturnValidator) bool {
+	if inm := c.Get(fiber.HeaderIfNoneMatch); inm != "" {
+		etag := versionETag(v.Version)
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+	// Last-Modified has one-second resolution
+	return !v.UpdatedAt.Truncate(time.Second).After(ims)
+}
+
+// setCacheHeaders sets ETag, Last-Modified and a status-dependent Cache-Control
+func setCacheHeaders(c *fiber.Ctx, v *store.ReturnValidator) {
+	maxAge := inFlightMaxAge
+	if v.Status == store.StatusCompleted {
+		maxAge = completedMaxAge
+	}
+	c.Set(fiber.HeaderETag, versionETag(v.Version))
+	c.Set(fiber.HeaderLastModified, v.UpdatedAt.UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderCacheControl, "private, max-age="+strconv.Itoa(int(maxAge.Seconds()))+", must-revalidate")
+}
@@ -3,18 +3,25 @@ package api
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"os"
+	"strings"
 	"time"
 
+	"refund-demo/internal/config"
+	"refund-demo/internal/logging"
+	"refund-demo/internal/metrics"
+	"refund-demo/internal/season"
 	"refund-demo/internal/store"
+	"refund-demo/internal/tracing"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/jmoiron/sqlx"
-	"github.com/rs/zerolog/log"
 	openai "github.com/sashabaranov/go-openai"
 	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ExplainRequest struct {
@@ -22,13 +29,8 @@ type ExplainRequest struct {
 	Question string `json:"question"`
 }
 
-func ExplainHandler(db *sqlx.DB) fiber.Handler {
+func ExplainHandler(db *store.DB, llm config.LLM, drainer *Drainer, seasons *season.Calendar) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		c.Set("Content-Type", "text/event-stream")
-		c.Set("Cache-Control", "no-cache")
-		c.Set("Connection", "keep-alive")
-		c.Set("Transfer-Encoding", "chunked")
-
 		// Parse request body (optional)
 		var req ExplainRequest
 		if err := c.BodyParser(&req); err != nil {
@@ -36,57 +38,130 @@ func ExplainHandler(db *sqlx.DB) fiber.Handler {
 			req.Question = "Why is my refund taking longer than expected?"
 		}
 
+		// Fetch return data if ID provided; ownership is checked before the
+		// details are loaded and again on what was loaded, before streaming
+		// starts. Unknown and inaccessible returns get the same 404.
+		var refundData *store.RefundReturn
+		if req.ReturnID != "" {
+			withReturnID(c, req.ReturnID)
+			v, err := store.GetReturnValidator(c.UserContext(), db, req.ReturnID)
+			if err != nil {
+				return sendError(c, 404, CodeNotFound, "not found")
+			}
+			if !canAccessReturn(c, v.OwnerID) {
+				return sendInaccessible(c)
+			}
+			r, err := store.GetReturnDetails(c.UserContext(), db, req.ReturnID)
+			if err != nil {
+				logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to fetch return")
+			} else if !canAccessReturn(c, r.OwnerID) {
+				return sendInaccessible(c)
+			} else {
+				refundData = r
+			}
+		}
+
+		// State returns are processed by the state, not the IRS
+		agency := "IRS"
+		var filingSeason *season.Season
+		if refundData != nil {
+			if j, ok := store.LookupJurisdiction(refundData.Jurisdiction); ok {
+				agency = j.Name
+			}
+			filingSeason, _ = seasons.For(refundData.TaxYear)
+		}
+
+		// Track the stream so shutdown can wait for it to finish
+		release, ok := drainer.track()
+		if !ok {
+			return sendDraining(c)
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("Transfer-Encoding", "chunked")
+
+		// The body is written after the handler returns, so carry the request
+		// span and logger over to the drainer context that cancels on shutdown
+		span := trace.SpanFromContext(c.UserContext())
+		logger := logging.Ctx(c.UserContext())
+
 		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer release()
+			ctx := logger.WithContext(trace.ContextWithSpan(drainer.Context(), span))
+
+			outcome := metrics.OutcomeCompleted
+			finish := metrics.StreamStarted("explain")
+			defer func() { finish(outcome) }()
+
 			// Step 1: Show thinking step - Analyzing return
 			fmt.Fprintf(w, "data: {\"type\":\"step\",\"content\":\"🔍 Analyzing your return...\"}\n\n")
 			w.Flush()
-			time.Sleep(300 * time.Millisecond)
-
-			// Fetch return data if ID provided
-			var refundData *store.RefundReturn
-			var err error
-			if req.ReturnID != "" {
-				refundData, err = store.GetReturnByID(db, req.ReturnID)
-				if err != nil {
-					log.Error().Err(err).Str("return_id", req.ReturnID).Msg("failed to fetch return")
-				}
+			if !pause(ctx, 300*time.Millisecond) {
+				outcome = writeInterrupted(w)
+				return
 			}
 
-			// Step 2: Show thinking step - Checking IRS data
-			fmt.Fprintf(w, "data: {\"type\":\"step\",\"content\":\"📊 Checking IRS processing times...\"}\n\n")
+			// Step 2: Show thinking step - Checking the agency's processing times
+			fmt.Fprintf(w, "data: {\"type\":\"step\",\"content\":\"📊 Checking %s processing times...\"}\n\n", agency)
 			w.Flush()
-			time.Sleep(300 * time.Millisecond)
+			if !pause(ctx, 300*time.Millisecond) {
+				outcome = writeInterrupted(w)
+				return
+			}
 
 			// Step 3: Show thinking step - Generating explanation
 			fmt.Fprintf(w, "data: {\"type\":\"step\",\"content\":\"🤖 Generating personalized explanation...\"}\n\n")
 			w.Flush()
-			time.Sleep(300 * time.Millisecond)
+			if !pause(ctx, 300*time.Millisecond) {
+				outcome = writeInterrupted(w)
+				return
+			}
 
 			// Check if OpenAI is configured
-			apiKey := os.Getenv("OPENAI_API_KEY")
-			if apiKey == "" {
+			if llm.APIKey == "" {
 				// Fallback to demo mode if no API key
-				log.Warn().Msg("OPENAI_API_KEY not set, using demo mode")
-				streamDemoExplanation(w, refundData)
+				logger.Warn().Msg("OPENAI_API_KEY not set, using demo mode")
+				outcome = streamDemoExplanation(ctx, w, refundData, filingSeason)
 				return
 			}
 
 			// Stream from OpenAI
-			streamOpenAIExplanation(w, apiKey, req.Question, refundData)
+			outcome = streamOpenAIExplanation(ctx, w, llm, req.Question, refundData, filingSeason)
 		}))
 
 		return nil
 	}
 }
 
-func streamDemoExplanation(w *bufio.Writer, refundData *store.RefundReturn) {
+func streamDemoExplanation(ctx context.Context, w *bufio.Writer, refundData *store.RefundReturn, filingSeason *season.Season) string {
 	// Demo mode with personalized data
 	chunks := []string{
 		"Based on your filing information, your refund is taking a little longer than usual.",
 		"This is common for returns with your profile characteristics.",
 	}
+	if refundData != nil && hasReductions(refundData) {
+		// A smaller refund than expected needs explaining before any delay
+		chunks = reductionExplanation(refundData)
+	}
 
 	if refundData != nil {
+		chunks = append(chunks, seasonExplanation(refundData, filingSeason, time.Now())...)
+		if j, ok := store.LookupJurisdiction(refundData.Jurisdiction); ok && j.Code != store.JurisdictionFederal && refundData.Status != store.StatusCompleted {
+			chunks = append(chunks, fmt.Sprintf(
+				"This is your %s refund, which %s issues on its own schedule; state returns like yours typically take %d more days from this stage.",
+				j.Name, j.Name, j.TypicalDaysRemaining(refundData.Status),
+			))
+		}
+		for _, a := range refundData.Adjustments {
+			if !a.IsReduction() {
+				chunks = append(chunks, adjustmentSentence(a))
+			}
+		}
+		if r := refundData.Refund; r != nil && r.Adjusted != r.Original && !hasReductions(refundData) {
+			chunks = append(chunks, fmt.Sprintf("That brings your refund from %s to %s.", r.Original, r.Adjusted))
+		}
 		chunks = append(chunks, fmt.Sprintf(
 			"Your return (status: %s) has a confidence score of %.0f%% for the estimated date.",
 			refundData.Status, refundData.Confidence*100,
@@ -96,22 +171,28 @@ func streamDemoExplanation(w *bufio.Writer, refundData *store.RefundReturn) {
 	}
 
 	for _, msg := range chunks {
-		fmt.Fprintf(w, "data: {\"type\":\"content\",\"content\":\"%s\"}\n\n", msg)
+		// Adjustment descriptions are free text, so encode rather than splice
+		content, _ := json.Marshal(msg)
+		fmt.Fprintf(w, "data: {\"type\":\"content\",\"content\":%s}\n\n", content)
 		w.Flush()
-		time.Sleep(400 * time.Millisecond)
+		if !pause(ctx, 400*time.Millisecond) {
+			return writeInterrupted(w)
+		}
 	}
 
-	fmt.Fprint(w, "data: {\"type\":\"done\"}\n\n")
-	w.Flush()
+	return writeDone(w, metrics.OutcomeCompleted)
 }
 
-func streamOpenAIExplanation(w *bufio.Writer, apiKey, question string, refundData *store.RefundReturn) {
-	client := openai.NewClient(apiKey)
-	ctx := context.Background()
+func streamOpenAIExplanation(ctx context.Context, w *bufio.Writer, llm config.LLM, question string, refundData *store.RefundReturn, filingSeason *season.Season) string {
+	client := openai.NewClient(llm.APIKey)
 
 	// Build context from refund data
 	systemPrompt := `You are a helpful tax assistant explaining refund delays. 
-Be concise, friendly, and provide actionable information. Keep responses under 100 words.`
+Be concise, friendly, and provide actionable information. If a PATH Act hold or peak filing
+period is listed, explain it as a cause of the wait. Keep responses under 100 words.`
+	if refundData != nil && hasReductions(refundData) {
+		systemPrompt = reductionSystemPrompt
+	}
 
 	userPrompt := question
 	if refundData != nil {
@@ -124,12 +205,19 @@ Be concise, friendly, and provide actionable information. Keep responses under 1
 		if refundData.EtaDate != nil {
 			contextData += fmt.Sprintf("\n- Estimated Date: %s", refundData.EtaDate.Format("Jan 2, 2006"))
 		}
+		if j, ok := store.LookupJurisdiction(refundData.Jurisdiction); ok {
+			contextData += fmt.Sprintf("\n- Issued By: %s\n- Typical Days Remaining From This Stage: %d",
+				j.Name, j.TypicalDaysRemaining(refundData.Status))
+		}
+		contextData += seasonPrompt(refundData, filingSeason, time.Now())
+		contextData += refundPrompt(refundData)
+		contextData += snapContextPrompt(refundData.SnapContext)
 		userPrompt += contextData
 	}
 
 	req := openai.ChatCompletionRequest{
-		Model:     openai.GPT4oMini, // Using GPT-4o-mini for cost efficiency
-		MaxTokens: 200,
+		Model:     llm.Model, // Defaults to GPT-4o-mini for cost efficiency
+		MaxTokens: llm.MaxTokens,
 		Messages: []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
@@ -141,18 +229,48 @@ Be concise, friendly, and provide actionable information. Keep responses under 1
 			},
 		},
 		Stream: true,
+		// Ask for a final chunk carrying token usage
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
 	}
 
+	ctx, span := tracing.Tracer().Start(ctx, "llm.stream", trace.WithAttributes(
+		attribute.String("llm.model", llm.Model),
+		attribute.Int("llm.max_tokens", llm.MaxTokens),
+	))
+	defer span.End()
+
+	start := time.Now()
 	stream, err := client.CreateChatCompletionStream(ctx, req)
+	if err != nil && ctx.Err() != nil {
+		span.SetAttributes(attribute.String("llm.outcome", metrics.OutcomeInterrupted))
+		metrics.LLMRequest(llm.Model, metrics.OutcomeInterrupted, time.Since(start), 0, 0)
+		return writeInterrupted(w)
+	}
 	if err != nil {
-		log.Error().Err(err).Msg("failed to create OpenAI stream")
+		logging.Ctx(ctx).Error().Err(err).Msg("failed to create OpenAI stream")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "connect failed")
+		metrics.LLMError(llm.Model, "connect")
+		metrics.LLMRequest(llm.Model, metrics.OutcomeError, time.Since(start), 0, 0)
 		fmt.Fprintf(w, "data: {\"type\":\"error\",\"content\":\"Error connecting to AI service. Please try again.\"}\n\n")
-		fmt.Fprint(w, "data: {\"type\":\"done\"}\n\n")
-		w.Flush()
-		return
+		return writeDone(w, metrics.OutcomeError)
 	}
 	defer stream.Close()
 
+	var usage openai.Usage
+	var firstToken time.Duration
+	outcome := metrics.OutcomeCompleted
+	defer func() {
+		metrics.LLMRequest(llm.Model, outcome, time.Since(start), usage.PromptTokens, usage.CompletionTokens)
+		span.SetAttributes(
+			attribute.String("llm.outcome", outcome),
+			attribute.Int64("llm.time_to_first_token_ms", firstToken.Milliseconds()),
+			attribute.Int64("llm.total_ms", time.Since(start).Milliseconds()),
+			attribute.Int("llm.usage.prompt_tokens", usage.PromptTokens),
+			attribute.Int("llm.usage.completion_tokens", usage.CompletionTokens),
+		)
+	}()
+
 	// Stream OpenAI response with proper chunking
 	accumulatedContent := ""
 	for {
@@ -160,13 +278,32 @@ Be concise, friendly, and provide actionable information. Keep responses under 1
 		if err == io.EOF {
 			break
 		}
+		if err != nil && ctx.Err() != nil {
+			// Shutdown deadline passed; flush what we have and end the stream
+			if accumulatedContent != "" {
+				fmt.Fprintf(w, "data: {\"type\":\"content\",\"content\":\"%s\"}\n\n", accumulatedContent)
+			}
+			outcome = writeInterrupted(w)
+			return outcome
+		}
 		if err != nil {
-			log.Error().Err(err).Msg("error receiving from OpenAI stream")
+			logging.Ctx(ctx).Error().Err(err).Msg("error receiving from OpenAI stream")
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "stream failed")
+			metrics.LLMError(llm.Model, "stream")
+			outcome = metrics.OutcomeError
 			break
 		}
+		if response.Usage != nil {
+			usage = *response.Usage
+		}
 
 		if len(response.Choices) > 0 {
 			content := response.Choices[0].Delta.Content
+			if content != "" && firstToken == 0 {
+				firstToken = time.Since(start)
+				span.AddEvent("first_token")
+			}
 			if content != "" {
 				accumulatedContent += content
 
@@ -187,6 +324,269 @@ Be concise, friendly, and provide actionable information. Keep responses under 1
 		w.Flush()
 	}
 
+	outcome = writeDone(w, outcome)
+	return outcome
+}
+
+// refundPrompt lists the refund amounts and every adjustment so the model
+// explains the actual line items rather than guessing at them
+func refundPrompt(r *store.RefundReturn) string {
+	var b strings.Builder
+	if r.Refund != nil {
+		fmt.Fprintf(&b, "\n- Refund Claimed: %s", r.Refund.Original)
+		if r.Refund.Adjusted != r.Refund.Original {
+			fmt.Fprintf(&b, "\n- Refund After Adjustments: %s", r.Refund.Adjusted)
+		}
+		if !r.Refund.Paid.IsZero() {
+			fmt.Fprintf(&b, "\n- Already Paid: %s\n- Still To Pay: %s", r.Refund.Paid, r.Refund.Remaining)
+		}
+	}
+	for _, a := range r.Adjustments {
+		fmt.Fprintf(&b, "\n- Adjustment on %s (%s, %s)", a.OccurredAt.Format("Jan 2, 2006"), a.Kind, a.Amount())
+		if a.Description != "" {
+			b.WriteString(": " + a.Description)
+		}
+		if reason := a.Reason(); reason != "" {
+			b.WriteString("; reason: " + reason)
+		}
+		if a.Agency != "" {
+			b.WriteString("; agency: " + a.Agency)
+		}
+	}
+	return b.String()
+}
+
+// seasonPrompt gives the model the filing-season facts behind the estimate, so
+// it can point to a PATH Act hold or peak-season volume instead of guessing
+func seasonPrompt(r *store.RefundReturn, s *season.Season, now time.Time) string {
+	var b strings.Builder
+	if r.TaxYear != 0 {
+		fmt.Fprintf(&b, "\n- Tax Year: %d", r.TaxYear)
+	}
+	if s == nil {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\n- Filing Season Opened: %s", s.Opens.Format("Jan 2, 2006"))
+	if r.CreatedAt.Before(s.Opens) {
+		b.WriteString(" (filed before opening; processing started then)")
+	}
+	if credits := heldCredits(r.SnapContext); credits != "" {
+		fmt.Fprintf(&b, "\n- PATH Act Hold: refunds claiming the %s are not issued before %s", credits, s.PATHRelease.Format("Jan 2, 2006"))
+		j, ok := store.LookupJurisdiction(r.Jurisdiction)
+		switch {
+		case !ok || !store.PATHHoldApplies(j, r.Status, r.SnapContext):
+			b.WriteString(" (does not apply to this return now)")
+		case s.PATHHeld(now):
+			b.WriteString(" (this return is held)")
+		default:
+			b.WriteString(" (hold has lifted)")
+		}
+	}
+	if p, ok := s.PeakAt(now); ok {
+		fmt.Fprintf(&b, "\n- Peak Filing Period: through %s, adding about %d days", p.End.Format("Jan 2"), p.ExtraDays)
+	}
+	return b.String()
+}
+
+// seasonExplanation is the demo text for how the filing season affects a
+// return: filing before the season opened, the PATH Act hold and peak volume
+func seasonExplanation(r *store.RefundReturn, s *season.Season, now time.Time) []string {
+	credits := heldCredits(r.SnapContext)
+	if s == nil {
+		// Dates for this tax year are unknown; the hold itself is still the law
+		if credits != "" {
+			return []string{"Returns claiming the Earned Income or Additional Child Tax Credit are held by law until mid-February."}
+		}
+		return nil
+	}
+
+	var chunks []string
+	if r.CreatedAt.Before(s.Opens) {
+		chunks = append(chunks, fmt.Sprintf(
+			"You filed before the IRS started accepting %d returns on %s, so processing started then.",
+			r.TaxYear, s.Opens.Format("Jan 2")))
+	}
+	if j, ok := store.LookupJurisdiction(r.Jurisdiction); ok && store.PATHHoldApplies(j, r.Status, r.SnapContext) {
+		if s.PATHHeld(now) {
+			chunks = append(chunks, fmt.Sprintf(
+				"Because you claimed the %s, the PATH Act requires the IRS to hold your refund until %s.",
+				credits, s.PATHRelease.Format("January 2")))
+		} else if r.CreatedAt.Before(s.PATHRelease) {
+			chunks = append(chunks, fmt.Sprintf(
+				"Because you claimed the %s, the PATH Act held your refund until %s, which added to the wait.",
+				credits, s.PATHRelease.Format("January 2")))
+		}
+	}
+	if p, ok := s.PeakAt(now); ok && r.Status != store.StatusCompleted {
+		chunks = append(chunks, fmt.Sprintf(
+			"The IRS is in one of the busiest stretches of the filing season through %s, which adds about %d days to processing.",
+			p.End.Format("Jan 2"), p.ExtraDays))
+	}
+	return chunks
+}
+
+// heldCredits names the PATH Act credits a return claims, or "" if none
+func heldCredits(sc store.SnapContext) string {
+	switch {
+	case sc.EITC && sc.ACTC:
+		return "Earned Income and Additional Child Tax Credits"
+	case sc.EITC:
+		return "Earned Income Tax Credit"
+	case sc.ACTC:
+		return "Additional Child Tax Credit"
+	}
+	return ""
+}
+
+// reductionSystemPrompt replaces the delay prompt when offsets or corrections
+// made the refund smaller than the taxpayer expects
+const reductionSystemPrompt = `You are a helpful tax assistant explaining why a refund is smaller than expected.
+For each offset, say how much went to which agency and for what kind of debt, and that questions
+about the debt go to that agency, not the IRS. For each correction, say what changed and that the
+taxpayer has 60 days from the IRS notice to dispute a math-error adjustment. Use only the amounts
+given. Be concise and friendly. Keep responses under 120 words.`
+
+// hasReductions reports whether any adjustment lowered the refund
+func hasReductions(r *store.RefundReturn) bool {
+	for i := range r.Adjustments {
+		if r.Adjustments[i].IsReduction() {
+			return true
+		}
+	}
+	return false
+}
+
+// reductionExplanation is the demo template for refunds reduced by offsets or
+// corrections: the overall change, each reduction, then what the taxpayer can do
+func reductionExplanation(r *store.RefundReturn) []string {
+	var chunks []string
+	if r.Refund != nil {
+		chunks = append(chunks, fmt.Sprintf("Your refund was reduced from %s to %s.", r.Refund.Original, r.Refund.Adjusted))
+	} else {
+		chunks = append(chunks, "Your refund was reduced after your return was processed.")
+	}
+
+	var offset, correction bool
+	for _, a := range r.Adjustments {
+		if !a.IsReduction() {
+			continue
+		}
+		chunks = append(chunks, reductionSentence(a))
+		if a.Kind == store.AdjustmentOffset {
+			offset = true
+		} else {
+			correction = true
+		}
+	}
+
+	if offset {
+		chunks = append(chunks,
+			"Offsets are made by the Treasury Offset Program for debts reported by other agencies. "+
+				"The IRS can't reverse them; contact the agency listed, or call the Treasury Offset Program at 800-304-3107.")
+	}
+	if correction {
+		chunks = append(chunks,
+			"The IRS will mail a notice explaining the correction. "+
+				"If you disagree, respond within 60 days of the notice; the IRS must then reverse the correction, though it may still examine the item.")
+	}
+	return chunks
+}
+
+// reductionSentence explains one offset or downward correction
+func reductionSentence(a store.Adjustment) string {
+	amount := a.Amount().Abs()
+	date := a.OccurredAt.Format("Jan 2")
+	var s string
+	if a.Kind == store.AdjustmentOffset {
+		s = fmt.Sprintf("On %s, %s was applied to %s", date, amount, orDefault(a.Reason(), "a debt"))
+		if a.Agency != "" {
+			s += " owed to the " + a.Agency
+		}
+	} else {
+		s = fmt.Sprintf("On %s, your refund was reduced by %s to correct %s", date, amount, orDefault(a.Reason(), "your return"))
+	}
+	if a.Description != "" {
+		s += " (" + a.Description + ")"
+	}
+	return s + "."
+}
+
+func orDefault(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// adjustmentSentence explains one adjustment in the demo explanation
+func adjustmentSentence(a store.Adjustment) string {
+	var what string
+	switch a.Kind {
+	case store.AdjustmentOffset:
+		what = fmt.Sprintf("%s of your refund was applied as an offset", a.Amount().Abs())
+	case store.AdjustmentInterest:
+		what = fmt.Sprintf("%s of interest was added to your refund", a.Amount())
+	case store.AdjustmentPartialPayment:
+		what = fmt.Sprintf("%s of your refund has already been paid", a.Amount())
+	default:
+		what = fmt.Sprintf("Your refund was corrected by %s", a.Amount())
+	}
+	if a.Description != "" {
+		what += " (" + a.Description + ")"
+	}
+	return fmt.Sprintf("On %s, %s.", a.OccurredAt.Format("Jan 2"), what)
+}
+
+// snapContextPrompt describes the filing details for the model. Only the
+// masked account is included.
+func snapContextPrompt(sc store.SnapContext) string {
+	var b strings.Builder
+	if sc.FilingType != "" {
+		fmt.Fprintf(&b, "\n- Filing Status: %s", sc.FilingType)
+	}
+	switch sc.RefundMethod {
+	case store.RefundMethodDirectDeposit:
+		b.WriteString("\n- Delivery: direct deposit")
+		if account := sc.MaskedAccount(); account != "" {
+			b.WriteString(" to " + account)
+		}
+	case store.RefundMethodCheck:
+		b.WriteString("\n- Delivery: paper check")
+	}
+	if sc.EITC {
+		b.WriteString("\n- Claims EITC")
+	}
+	if sc.ACTC {
+		b.WriteString("\n- Claims ACTC")
+	}
+	return b.String()
+}
+
+// pause waits for d, returning false early if the shutdown deadline passes
+func pause(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// writeInterrupted ends an explanation cut short by shutdown with a terminal
+// event so clients know to retry rather than wait for more content
+func writeInterrupted(w *bufio.Writer) string {
+	fmt.Fprint(w, "data: {\"type\":\"error\",\"content\":\"The server is restarting. Please try again.\"}\n\n")
+	return writeDone(w, metrics.OutcomeInterrupted)
+}
+
+// writeDone sends the terminal done event and returns outcome, or
+// client_gone if the client disconnected before it could be delivered
+func writeDone(w *bufio.Writer, outcome string) string {
 	fmt.Fprint(w, "data: {\"type\":\"done\"}\n\n")
-	w.Flush()
+	if err := w.Flush(); err != nil {
+		return metrics.OutcomeClientGone
+	}
+	return outcome
 }
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+
+	"refund-demo/internal/logging"
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FilingHandler returns every federal and state return in a filing along with
+// the combined refund and the date the last refund is expected. The caller
+// must own all of them, which is checked before any details are loaded.
+func FilingHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		owners, err := store.ListFilingOwners(c.UserContext(), db, c.Params("id"))
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, 404, CodeNotFound, "not found")
+		case err != nil:
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to load filing owners")
+			return sendError(c, 500, CodeInternal, "failed to load filing")
+		}
+		for _, owner := range owners {
+			if !canAccessReturn(c, owner) {
+				return sendInaccessible(c)
+			}
+		}
+
+		view, err := store.GetFilingDetails(c.UserContext(), db, c.Params("id"))
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, 404, CodeNotFound, "not found")
+		case err != nil:
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to load filing")
+			return sendError(c, 500, CodeInternal, "failed to load filing")
+		}
+		return c.JSON(view)
+	}
+}
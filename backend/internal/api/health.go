@@ -0,0 +1,59 @@
+package api
+
+import (
+	"refund-demo/internal/health"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type healthResponse struct {
+	Status  health.Status            `json:"status"`
+	Service string                   `json:"service"`
+	Checks  map[string]health.Result `json:"checks,omitempty"`
+}
+
+// LivezHandler reports whether the process is alive. It only runs checks
+// that a restart could fix, so a database outage doesn't restart every replica.
+func LivezHandler(checker *health.Checker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return sendHealth(c, checker.Run(c.UserContext()))
+	}
+}
+
+// ReadyzHandler reports whether the replica should receive traffic. It fails
+// as soon as shutdown starts, and otherwise when a critical check fails;
+// degraded checks are reported but keep the replica in rotation.
+func ReadyzHandler(checker *health.Checker, drainer *Drainer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if drainer.Draining() {
+			return c.Status(503).JSON(healthResponse{Status: "draining", Service: "refund-demo"})
+		}
+		return sendHealth(c, checker.Run(c.UserContext()))
+	}
+}
+
+// HealthHandler answers the original `/health` contract: 200 with
+// {"status":"healthy"} while the process is serving. It runs no checks and
+// ignores draining, like `/livez`; probes that need dependencies use `/readyz`.
+func HealthHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return c.JSON(fiber.Map{
+			"status":  "healthy",
+			"service": "refund-demo",
+		})
+	}
+}
+
+func sendHealth(c *fiber.Ctx, report health.Report) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	status := 200
+	if report.Status == health.StatusFail {
+		status = 503
+	}
+	return c.Status(status).JSON(healthResponse{
+		Status:  report.Status,
+		Service: "refund-demo",
+		Checks:  report.Checks,
+	})
+}
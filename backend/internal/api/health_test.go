@@ -0,0 +1,30 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestHealthKeepsOriginalContract(t *testing.T) {
+	app := fiber.New()
+	app.Get("/health", HealthHandler())
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/health", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if body["status"] != "healthy" || body["service"] != "refund-demo" {
+		t.Errorf("body = %v, want status healthy for refund-demo", body)
+	}
+}
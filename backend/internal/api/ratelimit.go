@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"refund-demo/internal/logging"
+	"refund-demo/internal/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RatePolicy sets the quotas for one route. Each non-zero limit is a separate
+// bucket and a request must fit in all of them.
+type RatePolicy struct {
+	Name         string
+	PerIP        ratelimit.Limit
+	PerPrincipal ratelimit.Limit
+	PerReturn    ratelimit.Limit
+}
+
+// Default quotas. Explanations call a paid LLM so they are much tighter than
+// status reads; per-return limits stop a caller hammering one return. Return
+// buckets belong to the caller (or, with auth disabled, the IP), since the
+// return ID in a request is checked for ownership only after limiting and
+// must not let anyone drain another taxpayer's quota.
+var (
+	StatusRatePolicy = RatePolicy{
+		Name:         "status",
+		PerIP:        ratelimit.PerMinute(120),
+		PerPrincipal: ratelimit.PerMinute(60),
+		PerReturn:    ratelimit.PerMinute(30),
+	}
+	StreamRatePolicy = RatePolicy{
+		Name:         "stream",
+		PerIP:        ratelimit.PerMinute(20),
+		PerPrincipal: ratelimit.PerMinute(10),
+		PerReturn:    ratelimit.PerMinute(10),
+	}
+	ExplainRatePolicy = RatePolicy{
+		Name:         "explain",
+		PerIP:        ratelimit.PerMinute(10),
+		PerPrincipal: ratelimit.PerMinute(5),
+		PerReturn:    ratelimit.PerMinute(3),
+	}
+)
+
+// RateLimit enforces policy using buckets in limiter. It sets RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset for the most constrained bucket and
+// answers 429 with Retry-After once any bucket is empty. A rejected request
+// costs nothing: tokens taken from the other buckets are refunded. A nil
+// limiter disables limiting; store errors fail open.
+func RateLimit(limiter ratelimit.Store, policy RatePolicy) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if limiter == nil {
+			return c.Next()
+		}
+
+		type bucketKey struct {
+			key   string
+			limit ratelimit.Limit
+		}
+		var keys []bucketKey
+		caller := "ip:" + c.IP()
+		if policy.PerIP.Rate > 0 {
+			keys = append(keys, bucketKey{policy.Name + ":" + caller, policy.PerIP})
+		}
+		if p := principalFrom(c); p != nil {
+			caller = "principal:" + p.Owner
+			if policy.PerPrincipal.Rate > 0 {
+				keys = append(keys, bucketKey{policy.Name + ":" + caller, policy.PerPrincipal})
+			}
+		}
+		if id := rateLimitReturnID(c); id != "" && policy.PerReturn.Rate > 0 {
+			keys = append(keys, bucketKey{policy.Name + ":return:" + id + ":" + caller, policy.PerReturn})
+		}
+
+		ctx := c.UserContext()
+		var tightest *ratelimit.Result
+		var taken []bucketKey
+		for _, k := range keys {
+			res, err := limiter.Take(ctx, k.key, k.limit)
+			if err != nil {
+				logging.Ctx(ctx).Error().Err(err).Str("bucket", k.key).Msg("rate limiter unavailable, allowing request")
+				continue
+			}
+			if tightest == nil || !res.Allowed || (tightest.Allowed && res.Remaining < tightest.Remaining) {
+				tightest = &res
+			}
+			if !res.Allowed {
+				break
+			}
+			taken = append(taken, k)
+		}
+
+		if tightest != nil && !tightest.Allowed {
+			for _, k := range taken {
+				if err := limiter.Refund(ctx, k.key, k.limit); err != nil {
+					logging.Ctx(ctx).Warn().Err(err).Str("bucket", k.key).Msg("failed to refund rate limit token")
+				}
+			}
+		}
+
+		if tightest == nil {
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Set("RateLimit-Reset", ceilSeconds(tightest.Reset))
+
+		if !tightest.Allowed {
+			c.Set(fiber.HeaderRetryAfter, ceilSeconds(tightest.RetryAfter))
+			return sendError(c, 429, CodeRateLimited, "rate limit exceeded, retry later")
+		}
+		return c.Next()
+	}
+}
+
+// rateLimitReturnID finds the return a request targets, from the path or an explain body
+func rateLimitReturnID(c *fiber.Ctx) string {
+	if id := c.Params("id"); id != "" {
+		return id
+	}
+	var body struct {
+		ReturnID string `json:"return_id"`
+	}
+	if len(c.Body()) > 0 && json.Unmarshal(c.Body(), &body) == nil {
+		return body.ReturnID
+	}
+	return ""
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
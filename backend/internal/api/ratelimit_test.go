@@ -0,0 +1,187 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"refund-demo/internal/auth"
+	"refund-demo/internal/config"
+	"refund-demo/internal/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newRateLimitedApp serves /status/:id behind policy. The X-Owner header
+// stands in for an authenticated principal.
+func newRateLimitedApp(limiter ratelimit.Store, policy RatePolicy) *fiber.App {
+	return mountRateLimited(fiber.New(), limiter, policy)
+}
+
+func mountRateLimited(app *fiber.App, limiter ratelimit.Store, policy RatePolicy) *fiber.App {
+	app.Use(func(c *fiber.Ctx) error {
+		if owner := c.Get("X-Owner"); owner != "" {
+			c.Locals(principalKey, &auth.Principal{Subject: owner, Owner: owner})
+		}
+		return c.Next()
+	})
+	app.Get("/status/:id", RateLimit(limiter, policy), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	return app
+}
+
+func getStatus(t *testing.T, app *fiber.App, id, owner string) (int, map[string]string) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/status/"+id, nil)
+	if owner != "" {
+		req.Header.Set("X-Owner", owner)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test() = %v", err)
+	}
+	defer resp.Body.Close()
+	headers := map[string]string{}
+	for _, h := range []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"} {
+		headers[h] = resp.Header.Get(h)
+	}
+	return resp.StatusCode, headers
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	app := newRateLimitedApp(ratelimit.NewMemoryStore(), RatePolicy{
+		Name:      "test",
+		PerIP:     ratelimit.PerMinute(10),
+		PerReturn: ratelimit.PerMinute(2),
+	})
+
+	status, h := getStatus(t, app, "r1", "")
+	if status != fiber.StatusNoContent {
+		t.Fatalf("first request = %d, want 204", status)
+	}
+	if h["RateLimit-Limit"] != "2" || h["RateLimit-Remaining"] != "1" || h["RateLimit-Reset"] != "30" {
+		t.Fatalf("headers = %v, want the per-return bucket: limit 2, remaining 1, reset 30", h)
+	}
+	if h["Retry-After"] != "" {
+		t.Fatalf("Retry-After = %q on an allowed request", h["Retry-After"])
+	}
+
+	getStatus(t, app, "r1", "")
+	status, h = getStatus(t, app, "r1", "")
+	if status != fiber.StatusTooManyRequests {
+		t.Fatalf("third request = %d, want 429", status)
+	}
+	if h["RateLimit-Remaining"] != "0" || h["Retry-After"] != "30" {
+		t.Fatalf("headers = %v, want remaining 0 and Retry-After 30", h)
+	}
+}
+
+func TestRateLimitRejectionDebitsNothing(t *testing.T) {
+	app := newRateLimitedApp(ratelimit.NewMemoryStore(), RatePolicy{
+		Name:      "test",
+		PerIP:     ratelimit.PerMinute(3),
+		PerReturn: ratelimit.PerMinute(1),
+	})
+
+	getStatus(t, app, "r1", "")
+	for i := 0; i < 5; i++ {
+		if status, _ := getStatus(t, app, "r1", ""); status != fiber.StatusTooManyRequests {
+			t.Fatalf("repeat request = %d, want 429", status)
+		}
+	}
+
+	// Rejected requests were refunded, so the IP bucket still has two tokens
+	for _, id := range []string{"r2", "r3"} {
+		if status, _ := getStatus(t, app, id, ""); status != fiber.StatusNoContent {
+			t.Fatalf("request for %s = %d, want 204; rejected requests drained the IP bucket", id, status)
+		}
+	}
+	if status, _ := getStatus(t, app, "r4", ""); status != fiber.StatusTooManyRequests {
+		t.Fatalf("request beyond the IP quota = %d, want 429", status)
+	}
+}
+
+func TestRateLimitReturnBucketsArePerCaller(t *testing.T) {
+	app := newRateLimitedApp(ratelimit.NewMemoryStore(), RatePolicy{
+		Name:      "test",
+		PerReturn: ratelimit.PerMinute(1),
+	})
+
+	getStatus(t, app, "victim-return", "attacker")
+	if status, _ := getStatus(t, app, "victim-return", "attacker"); status != fiber.StatusTooManyRequests {
+		t.Fatalf("attacker's second request = %d, want 429", status)
+	}
+	if status, _ := getStatus(t, app, "victim-return", "victim"); status != fiber.StatusNoContent {
+		t.Fatalf("owner's request = %d, want 204; another caller drained the owner's quota", status)
+	}
+}
+
+func TestRateLimitReturnIDFromBody(t *testing.T) {
+	app := fiber.New()
+	app.Post("/explain", RateLimit(ratelimit.NewMemoryStore(), RatePolicy{Name: "test", PerReturn: ratelimit.PerMinute(1)}), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	post := func(id string) int {
+		req := httptest.NewRequest("POST", "/explain", strings.NewReader(`{"return_id":"`+id+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test() = %v", err)
+		}
+		return resp.StatusCode
+	}
+	if post("r1") != fiber.StatusNoContent || post("r1") != fiber.StatusTooManyRequests {
+		t.Fatal("explain requests for one return were not limited by return_id")
+	}
+	if post("r2") != fiber.StatusNoContent {
+		t.Fatal("explain request for another return was limited")
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	app := newRateLimitedApp(nil, StatusRatePolicy)
+	for i := 0; i < 5; i++ {
+		status, h := getStatus(t, app, "r1", "")
+		if status != fiber.StatusNoContent || h["RateLimit-Limit"] != "" {
+			t.Fatalf("request with no limiter = %d with headers %v, want 204 and no headers", status, h)
+		}
+	}
+}
+
+func TestRateLimitForwardedClients(t *testing.T) {
+	policy := RatePolicy{Name: "test", PerIP: ratelimit.PerMinute(1)}
+	// app.Test connections come from 0.0.0.0
+	tests := []struct {
+		name     string
+		proxies  []string
+		separate bool
+	}{
+		{"no trusted proxies", nil, false},
+		{"from a trusted proxy", []string{"0.0.0.0"}, true},
+		{"from a trusted range", []string{"0.0.0.0/8"}, true},
+		{"from an untrusted address", []string{"10.0.0.1"}, false},
+	}
+	for _, tt := range tests {
+		app := mountRateLimited(fiber.New(AppConfig("test", config.Server{TrustedProxies: tt.proxies})), ratelimit.NewMemoryStore(), policy)
+		var statuses []int
+		for _, client := range []string{"203.0.113.7", "198.51.100.9, 10.0.0.1"} {
+			req := httptest.NewRequest("GET", "/status/r1", nil)
+			req.Header.Set(fiber.HeaderXForwardedFor, client)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test() = %v", err)
+			}
+			resp.Body.Close()
+			statuses = append(statuses, resp.StatusCode)
+		}
+		want := fiber.StatusTooManyRequests
+		if tt.separate {
+			want = fiber.StatusNoContent
+		}
+		if statuses[0] != fiber.StatusNoContent || statuses[1] != want {
+			t.Errorf("%s: two forwarded clients got %v, want [204 %d]", tt.name, statuses, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strconv"
+	"time"
+
+	"refund-demo/internal/logging"
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ETAAccuracyResponse is the body of GET /internal/reports/eta-accuracy
+type ETAAccuracyResponse struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Rows        []store.ETAAccuracy `json:"rows"`
+}
+
+// ETAAccuracyHandler compares past estimates with when refunds actually
+// arrived, per tax year and stage; ?tax_year= narrows it to one season
+func ETAAccuracyHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		taxYear, err := parseTaxYear(c)
+		if err != nil {
+			return sendError(c, 400, CodeBadRequest, err.Error())
+		}
+
+		rows, err := store.ETAAccuracyReport(c.UserContext(), db, taxYear)
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to build ETA accuracy report")
+			return sendError(c, 500, CodeInternal, "failed to build report")
+		}
+		return c.JSON(ETAAccuracyResponse{GeneratedAt: time.Now().UTC(), Rows: rows})
+	}
+}
+
+// ETAReliabilityResponse is the JSON body of GET /internal/reports/eta-calibration
+type ETAReliabilityResponse struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	*store.ReliabilityReport
+	// Calibration is the mapping applied to new estimates, or null before the
+	// first one is fitted
+	Calibration *store.Calibration `json:"calibration"`
+}
+
+// ETAReliabilityHandler buckets past estimates by the confidence users were
+// shown and compares each bucket with how often refunds arrived on time.
+// ?format=csv returns the buckets as CSV for spreadsheets.
+func ETAReliabilityHandler(db *store.DB, eta *store.ETAEngine) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		format := c.Query("format", "json")
+		if format != "json" && format != "csv" {
+			return sendError(c, 400, CodeBadRequest, "format must be json or csv")
+		}
+		taxYear, err := parseTaxYear(c)
+		if err != nil {
+			return sendError(c, 400, CodeBadRequest, err.Error())
+		}
+
+		report, err := store.ETAReliabilityReport(c.UserContext(), db, taxYear)
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to build ETA reliability report")
+			return sendError(c, 500, CodeInternal, "failed to build report")
+		}
+
+		if format == "csv" {
+			c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+			return c.Send(reliabilityCSV(report))
+		}
+		return c.JSON(ETAReliabilityResponse{
+			GeneratedAt:       time.Now().UTC(),
+			ReliabilityReport: report,
+			Calibration:       eta.Calibration(),
+		})
+	}
+}
+
+// reliabilityCSV writes one row per confidence bucket
+func reliabilityCSV(report *store.ReliabilityReport) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"lower", "upper", "predictions", "mean_confidence", "on_time_rate"})
+	for _, b := range report.Buckets {
+		w.Write([]string{
+			strconv.FormatFloat(b.Lower, 'f', 1, 64),
+			strconv.FormatFloat(b.Upper, 'f', 1, 64),
+			strconv.Itoa(b.Predictions),
+			strconv.FormatFloat(b.MeanConfidence, 'f', 4, 64),
+			strconv.FormatFloat(b.OnTimeRate, 'f', 4, 64),
+		})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// parseTaxYear reads the optional ?tax_year= filter; zero means every year
+func parseTaxYear(c *fiber.Ctx) (int, error) {
+	raw := c.Query("tax_year")
+	if raw == "" {
+		return 0, nil
+	}
+	year, err := strconv.Atoi(raw)
+	if err != nil || year < 2000 || year > 2100 {
+		return 0, errors.New("invalid tax_year")
+	}
+	return year, nil
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"slices"
+	"strings"
+
+	"refund-demo/internal/logging"
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StalledReturnsHandler lists the returns the stall detector has flagged, most
+// recently flagged first; ?status= narrows it to ACCEPTED or REVIEW
+func StalledReturnsHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		status := c.Query("status")
+		if status != "" && !slices.Contains(store.StallStages, status) {
+			return sendError(c, 400, CodeBadRequest, "status must be one of "+strings.Join(store.StallStages, ", "))
+		}
+		limit := c.QueryInt("limit", 100)
+		if limit <= 0 || limit > 1000 {
+			limit = 100
+		}
+
+		stalled, err := store.ListStalledReturns(c.UserContext(), db, status, limit)
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to list stalled returns")
+			return sendError(c, 500, CodeInternal, "failed to list stalled returns")
+		}
+		return c.JSON(stalled)
+	}
+}
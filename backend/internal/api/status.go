@@ -1,34 +1,137 @@
 package api
 
 import (
+	"database/sql"
+	"errors"
+
+	"refund-demo/internal/auth"
+	"refund-demo/internal/config"
+	"refund-demo/internal/logging"
+	"refund-demo/internal/ratelimit"
 	"refund-demo/internal/store"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/jmoiron/sqlx"
 )
 
-func RegisterRoutes(app *fiber.App, db *sqlx.DB) {
-	api := app.Group("/v1")
-	
-	api.Get("/status/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
-		status, err := store.GetReturnByID(db, id)
-		if err != nil {
-			return c.Status(404).JSON(fiber.Map{"error": "not found"})
-		}
-		return c.JSON(status)
-	})
+type TransitionRequest struct {
+	Status string `json:"status"`
+}
 
-	api.Post("/status/explain", ExplainHandler(db))
+func RegisterRoutes(app *fiber.App, db *store.DB, cfg *config.Config, broker *store.StatusBroker, verifier *auth.Verifier, limiter ratelimit.Store, drainer *Drainer, eta *store.ETAEngine) {
+	api := app.Group("/v1", RequireAuth(verifier))
 	
-	app.Post("/internal/scrape", func(c *fiber.Ctx) error {
-		returnID, err := store.InsertDemoReturn(db)
+	api.Get("/status/:id", RateLimit(limiter, StatusRatePolicy), StatusHandler(db))
+
+	api.Get("/status/:id/stream", RateLimit(limiter, StreamRatePolicy), StatusStreamHandler(db, broker, drainer))
+
+	api.Get("/filings/:id", RateLimit(limiter, StatusRatePolicy), FilingHandler(db))
+
+	api.Post("/status/explain", RateLimit(limiter, ExplainRatePolicy), ExplainHandler(db, cfg.LLM, drainer, eta.Seasons))
+}
+
+// RegisterInternalRoutes mounts the admin routes on router, which is either the
+// public app or a separate admin listener. Every route requires an API key with
+// the appropriate scope and every request is written to the audit log.
+func RegisterInternalRoutes(router fiber.Router, db *store.DB, eta *store.ETAEngine) {
+	internal := router.Group("/internal", AuditAdminActions(db), RequireAPIKey(db))
+
+	internal.Post("/scrape", RequireScope(store.ScopeReturnsWrite), func(c *fiber.Ctx) error {
+		returnID, err := store.InsertDemoReturn(c.UserContext(), db, eta)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "failed to insert demo data"})
+			return sendError(c, 500, CodeInternal, "failed to insert demo data")
 		}
 		return c.JSON(fiber.Map{
 			"message": "demo data inserted",
 			"return_id": returnID,
 		})
 	})
+
+	internal.Post("/returns/:id/transition", RequireScope(store.ScopeReturnsWrite), TransitionHandler(db, eta))
+	internal.Get("/returns/stalled", RequireScope(store.ScopeReturnsRead), StalledReturnsHandler(db))
+	internal.Get("/reports/eta-accuracy", RequireScope(store.ScopeReturnsRead), ETAAccuracyHandler(db))
+	internal.Get("/reports/eta-calibration", RequireScope(store.ScopeReturnsRead), ETAReliabilityHandler(db, eta))
+	internal.Post("/returns/:id/adjustments", RequireScope(store.ScopeReturnsWrite), CreateAdjustmentHandler(db))
+
+	internal.Get("/webhooks", RequireScope(store.ScopeWebhooksRead), ListWebhooksHandler(db))
+	internal.Post("/webhooks", RequireScope(store.ScopeWebhooksWrite), CreateWebhookHandler(db))
+	internal.Delete("/webhooks/:id", RequireScope(store.ScopeWebhooksWrite), DeleteWebhookHandler(db))
+	internal.Get("/webhooks/deliveries", RequireScope(store.ScopeWebhooksRead), ListWebhookDeliveriesHandler(db))
+	internal.Post("/webhooks/deliveries/:id/retry", RequireScope(store.ScopeWebhooksWrite), RetryWebhookDeliveryHandler(db))
+
+	internal.Get("/keys", RequireScope(store.ScopeAdmin), ListAPIKeysHandler(db))
+	internal.Post("/keys", RequireScope(store.ScopeAdmin), CreateAPIKeyHandler(db))
+	internal.Delete("/keys/:id", RequireScope(store.ScopeAdmin), RevokeAPIKeyHandler(db))
+	internal.Get("/audit", RequireScope(store.ScopeAdmin), ListAuditLogHandler(db))
+}
+
+// StatusHandler returns a return's current status. Ownership is checked from
+// the validator columns before any details are loaded. Responses carry ETag
+// and Last-Modified validators; conditional requests for an unchanged return
+// get a 304 without reading anything more.
+func StatusHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		withReturnID(c, id)
+
+		v, err := store.GetReturnValidator(c.UserContext(), db, id)
+		if err != nil {
+			return sendError(c, 404, CodeNotFound, "not found")
+		}
+		if !canAccessReturn(c, v.OwnerID) {
+			return sendInaccessible(c)
+		}
+		if isConditionalGet(c) && notModified(c, v) {
+			setCacheHeaders(c, v)
+			return c.SendStatus(304)
+		}
+
+		status, err := store.GetReturnDetails(c.UserContext(), db, id)
+		if err != nil {
+			return sendError(c, 404, CodeNotFound, "not found")
+		}
+		setCacheHeaders(c, &store.ReturnValidator{
+			Status:    status.Status,
+			Version:   status.Version,
+			UpdatedAt: status.UpdatedAt,
+			OwnerID:   status.OwnerID,
+		})
+		return c.JSON(status)
+	}
+}
+
+// TransitionHandler moves a return to a new status, which enqueues webhook
+// deliveries for matching subscribers. An If-Match header holding the ETag
+// from a previous response makes the transition conditional on that version.
+// The return's ETA is re-estimated for its new status.
+func TransitionHandler(db *store.DB, eta *store.ETAEngine) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req TransitionRequest
+		if err := c.BodyParser(&req); err != nil {
+			return sendError(c, 400, CodeBadRequest, "invalid request body")
+		}
+
+		expectedVersion, err := parseIfMatch(c.Get(fiber.HeaderIfMatch))
+		if err != nil {
+			return sendError(c, 400, CodeBadRequest, err.Error())
+		}
+
+		withReturnID(c, c.Params("id"))
+		r, err := store.TransitionReturn(c.UserContext(), db, eta, c.Params("id"), req.Status, expectedVersion)
+		switch {
+		case errors.Is(err, store.ErrInvalidStatus):
+			return sendError(c, 400, CodeBadRequest, err.Error())
+		case errors.Is(err, sql.ErrNoRows):
+			return sendError(c, 404, CodeNotFound, "not found")
+		case errors.Is(err, store.ErrConflict) && expectedVersion != 0:
+			return sendError(c, 412, CodePreconditionFailed, "return has been modified; reload and retry")
+		case errors.Is(err, store.ErrConflict):
+			return sendError(c, 409, CodeConflict, err.Error())
+		case err != nil:
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to transition return")
+			return sendError(c, 500, CodeInternal, "failed to transition return")
+		}
+
+		c.Set(fiber.HeaderETag, versionETag(r.Version))
+		return c.JSON(r)
+	}
 }
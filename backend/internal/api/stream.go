@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"refund-demo/internal/logging"
+	"refund-demo/internal/metrics"
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// heartbeatInterval keeps proxies and load balancers from closing idle streams
+const heartbeatInterval = 15 * time.Second
+
+type statusEvent struct {
+	Type   string              `json:"type"`
+	Return *store.RefundReturn `json:"return,omitempty"`
+}
+
+// StatusStreamHandler emits the current state of a return and then pushes a
+// transition event whenever its status changes and an adjustment event when an
+// adjustment is recorded, fed by the Postgres status broker. When
+// the server starts draining it sends a shutdown event and closes the stream
+// so the client reconnects elsewhere.
+func StatusStreamHandler(db *store.DB, broker *store.StatusBroker, drainer *Drainer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		withReturnID(c, id)
+
+		release, ok := drainer.track()
+		if !ok {
+			return sendDraining(c)
+		}
+
+		// The request context carries the trace and logger; reloads in the stream reuse it
+		reqCtx := c.UserContext()
+
+		// Check ownership before subscribing or loading any details
+		v, err := store.GetReturnValidator(reqCtx, db, id)
+		if err != nil {
+			release()
+			return sendError(c, 404, CodeNotFound, "not found")
+		}
+		if !canAccessReturn(c, v.OwnerID) {
+			release()
+			return sendInaccessible(c)
+		}
+
+		// Subscribe before the initial read so a transition in between is not missed
+		changes, cancel := broker.Subscribe(id)
+
+		current, err := store.GetReturnDetails(reqCtx, db, id)
+		if err != nil {
+			cancel()
+			release()
+			return sendError(c, 404, CodeNotFound, "not found")
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("Transfer-Encoding", "chunked")
+		c.Set("X-Accel-Buffering", "no")
+
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer release()
+			defer cancel()
+
+			// Status streams only end when the client leaves or the server drains
+			outcome := metrics.OutcomeClientGone
+			finish := metrics.StreamStarted("status")
+			defer func() { finish(outcome) }()
+
+			fmt.Fprint(w, "retry: 3000\n\n")
+			if err := writeStatusEvent(w, "snapshot", current); err != nil {
+				return
+			}
+
+			heartbeat := time.NewTicker(heartbeatInterval)
+			defer heartbeat.Stop()
+
+			for {
+				select {
+				case <-drainer.Drain():
+					writeStatusEvent(w, "shutdown", nil)
+					outcome = metrics.OutcomeInterrupted
+					return
+				case <-changes:
+					latest, err := store.GetReturnDetails(reqCtx, db, id)
+					if err != nil {
+						logging.Ctx(reqCtx).Error().Err(err).Msg("failed to reload return for stream")
+						continue
+					}
+					eventType := statusChange(current, latest)
+					if eventType == "" {
+						continue
+					}
+					current = latest
+					if err := writeStatusEvent(w, eventType, current); err != nil {
+						return
+					}
+				case <-heartbeat.C:
+					fmt.Fprint(w, ": heartbeat\n\n")
+					if err := w.Flush(); err != nil {
+						// Client went away
+						return
+					}
+				}
+			}
+		}))
+
+		return nil
+	}
+}
+
+// statusChange names the stream event for a reload, or "" when nothing a
+// subscriber sees has changed, as after a reconnect broadcast
+func statusChange(current, latest *store.RefundReturn) string {
+	switch {
+	case latest.Status != current.Status:
+		return store.NotifyTransition
+	case len(latest.Adjustments) != len(current.Adjustments):
+		return store.NotifyAdjustment
+	default:
+		return ""
+	}
+}
+
+func writeStatusEvent(w *bufio.Writer, eventType string, r *store.RefundReturn) error {
+	data, err := json.Marshal(statusEvent{Type: eventType, Return: r})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	return w.Flush()
+}
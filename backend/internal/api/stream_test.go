@@ -0,0 +1,31 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"refund-demo/internal/store"
+)
+
+func TestStatusChange(t *testing.T) {
+	now := time.Now()
+	current := &store.RefundReturn{Status: store.StatusAccepted, Version: 3, UpdatedAt: now}
+	adjusted := []store.Adjustment{{Kind: store.AdjustmentOffset}}
+
+	tests := []struct {
+		name   string
+		latest *store.RefundReturn
+		want   string
+	}{
+		{"unchanged", &store.RefundReturn{Status: store.StatusAccepted, Version: 3, UpdatedAt: now}, ""},
+		{"touched without a visible change", &store.RefundReturn{Status: store.StatusAccepted, Version: 4, UpdatedAt: now.Add(time.Minute)}, ""},
+		{"status changed", &store.RefundReturn{Status: store.StatusApproved, Version: 4}, "transition"},
+		{"adjustment recorded", &store.RefundReturn{Status: store.StatusAccepted, Version: 4, Adjustments: adjusted}, "adjustment"},
+		{"both", &store.RefundReturn{Status: store.StatusApproved, Version: 5, Adjustments: adjusted}, "transition"},
+	}
+	for _, tt := range tests {
+		if got := statusChange(current, tt.latest); got != tt.want {
+			t.Errorf("%s: statusChange() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
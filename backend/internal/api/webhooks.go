@@ -0,0 +1,121 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+
+	"refund-demo/internal/logging"
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// CreateWebhookHandler registers a new webhook subscription. Events must be
+// known event types; none means all. If no secret is supplied one is
+// generated; the secret is only ever returned in this response.
+func CreateWebhookHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req CreateWebhookRequest
+		if err := c.BodyParser(&req); err != nil {
+			return sendError(c, 400, CodeBadRequest, "invalid request body")
+		}
+
+		u, err := url.Parse(req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return sendError(c, 400, CodeBadRequest, "url must be an absolute http(s) URL")
+		}
+		for _, event := range req.Events {
+			if !store.IsWebhookEventType(event) {
+				return sendError(c, 400, CodeBadRequest, "unknown event type "+strconv.Quote(event))
+			}
+		}
+
+		if req.Secret == "" {
+			buf := make([]byte, 32)
+			if _, err := rand.Read(buf); err != nil {
+				return sendError(c, 500, CodeInternal, "failed to generate secret")
+			}
+			req.Secret = hex.EncodeToString(buf)
+		}
+
+		sub, err := store.CreateWebhookSubscription(c.UserContext(), db, req.URL, req.Secret, req.Events)
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to create webhook subscription")
+			return sendError(c, 500, CodeInternal, "failed to create subscription")
+		}
+
+		return c.Status(201).JSON(fiber.Map{
+			"subscription": sub,
+			"secret":       req.Secret,
+		})
+	}
+}
+
+func ListWebhooksHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		subs, err := store.ListWebhookSubscriptions(c.UserContext(), db)
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to list webhook subscriptions")
+			return sendError(c, 500, CodeInternal, "failed to list subscriptions")
+		}
+		return c.JSON(subs)
+	}
+}
+
+func DeleteWebhookHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		deleted, err := store.DeleteWebhookSubscription(c.UserContext(), db, c.Params("id"))
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to delete webhook subscription")
+			return sendError(c, 500, CodeInternal, "failed to delete subscription")
+		}
+		if !deleted {
+			return sendError(c, 404, CodeNotFound, "not found")
+		}
+		return c.SendStatus(204)
+	}
+}
+
+// ListWebhookDeliveriesHandler shows recent deliveries; ?status=DEAD lists the dead-letter queue
+func ListWebhookDeliveriesHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		status := c.Query("status")
+		if status != "" && status != store.DeliveryPending && status != store.DeliveryDelivered && status != store.DeliveryDead {
+			return sendError(c, 400, CodeBadRequest, "invalid status filter")
+		}
+		limit := c.QueryInt("limit", 50)
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+
+		deliveries, err := store.ListWebhookDeliveries(c.UserContext(), db, status, limit)
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to list webhook deliveries")
+			return sendError(c, 500, CodeInternal, "failed to list deliveries")
+		}
+		return c.JSON(deliveries)
+	}
+}
+
+// RetryWebhookDeliveryHandler re-queues a dead-lettered delivery
+func RetryWebhookDeliveryHandler(db *store.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		retried, err := store.RetryWebhookDelivery(c.UserContext(), db, c.Params("id"))
+		if err != nil {
+			logging.Ctx(c.UserContext()).Error().Err(err).Msg("failed to retry webhook delivery")
+			return sendError(c, 500, CodeInternal, "failed to retry delivery")
+		}
+		if !retried {
+			return sendError(c, 404, CodeNotFound, "no dead delivery with that id")
+		}
+		return c.JSON(fiber.Map{"message": "delivery re-queued"})
+	}
+}
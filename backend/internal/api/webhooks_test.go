@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCreateWebhookRejectsUnknownEvents(t *testing.T) {
+	// Requests are rejected before the store is touched
+	app := fiber.New()
+	app.Post("/internal/webhooks", CreateWebhookHandler(nil))
+
+	for _, body := range []string{
+		`{"url":"https://example.com/hooks","events":["return.refunded"]}`,
+		`{"url":"https://example.com/hooks","events":["return.sent","RETURN.SENT"]}`,
+		`{"url":"ftp://example.com/hooks","events":["return.sent"]}`,
+	} {
+		req := httptest.NewRequest("POST", "/internal/webhooks", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test() = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("POST %s = %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"refund-demo/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// writeJWKS writes the public halves of keys, by kid, as a JWKS file along
+// with entries loadJWKS must skip
+func writeJWKS(t *testing.T, keys map[string]*rsa.PrivateKey) string {
+	t.Helper()
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA", Kid: kid, Use: "sig", Alg: "RS256",
+			N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	set.Keys = append(set.Keys,
+		jwk{Kty: "EC", Kid: "ec"},
+		jwk{Kty: "RSA", Kid: "enc", Use: "enc", N: "AQAB", E: "AQAB"},
+	)
+	data, _ := json.Marshal(set)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func claims(extra jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range extra {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+func TestVerify(t *testing.T) {
+	k1, k2, stranger := generateKey(t), generateKey(t), generateKey(t)
+	jwks := writeJWKS(t, map[string]*rsa.PrivateKey{"k1": k1, "k2": k2})
+	single := writeJWKS(t, map[string]*rsa.PrivateKey{"k1": k1})
+	publicDER, _ := x509.MarshalPKIXPublicKey(&k1.PublicKey)
+
+	hs := config.Auth{HS256Secret: testSecret}
+	rs := config.Auth{JWKSFile: jwks}
+	strict := config.Auth{HS256Secret: testSecret, Issuer: "https://issuer.test", Audience: "refund-demo"}
+	customOwner := config.Auth{HS256Secret: testSecret, OwnerClaim: "owner_id"}
+
+	tests := []struct {
+		name  string
+		cfg   config.Auth
+		token string
+		owner string
+		err   error
+	}{
+		{"HS256", hs, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(nil)), "user-1", nil},
+		{"HS256 wrong secret", hs, sign(t, jwt.SigningMethodHS256, []byte("other"), "", claims(nil)), "", ErrInvalidToken},
+		{"HS256 against RS256 config", rs, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(nil)), "", ErrInvalidToken},
+		{"HS256 signed with the RSA public key", rs, sign(t, jwt.SigningMethodHS256, publicDER, "k1", claims(nil)), "", ErrInvalidToken},
+		{"RS256 against HS256 config", hs, sign(t, jwt.SigningMethodRS256, k1, "k1", claims(nil)), "", ErrInvalidToken},
+		{"alg none", hs, sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", claims(nil)), "", ErrInvalidToken},
+		{"expired", hs, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})), "", ErrInvalidToken},
+		{"missing exp", hs, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(jwt.MapClaims{"exp": nil})), "", ErrInvalidToken},
+		{"not yet valid", hs, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()})), "", ErrInvalidToken},
+		{"malformed", hs, "not.a.token", "", ErrInvalidToken},
+		{"issuer and audience", strict, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(jwt.MapClaims{"iss": "https://issuer.test", "aud": "refund-demo"})), "user-1", nil},
+		{"wrong issuer", strict, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(jwt.MapClaims{"iss": "https://evil.test", "aud": "refund-demo"})), "", ErrInvalidToken},
+		{"missing issuer", strict, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(jwt.MapClaims{"aud": "refund-demo"})), "", ErrInvalidToken},
+		{"wrong audience", strict, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(jwt.MapClaims{"iss": "https://issuer.test", "aud": "other-app"})), "", ErrInvalidToken},
+		{"missing owner", hs, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(jwt.MapClaims{"sub": nil})), "", ErrMissingOwner},
+		{"custom owner claim", customOwner, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(jwt.MapClaims{"owner_id": "owner-9"})), "owner-9", nil},
+		{"custom owner claim missing", customOwner, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(nil)), "", ErrMissingOwner},
+		{"RS256 kid k1", rs, sign(t, jwt.SigningMethodRS256, k1, "k1", claims(nil)), "user-1", nil},
+		{"RS256 kid k2", rs, sign(t, jwt.SigningMethodRS256, k2, "k2", claims(nil)), "user-1", nil},
+		{"RS256 kid of another key", rs, sign(t, jwt.SigningMethodRS256, k2, "k1", claims(nil)), "", ErrInvalidToken},
+		{"RS256 unknown kid", rs, sign(t, jwt.SigningMethodRS256, k1, "k3", claims(nil)), "", ErrInvalidToken},
+		{"RS256 skipped kid", rs, sign(t, jwt.SigningMethodRS256, k1, "enc", claims(nil)), "", ErrInvalidToken},
+		{"RS256 no kid with several keys", rs, sign(t, jwt.SigningMethodRS256, k1, "", claims(nil)), "", ErrInvalidToken},
+		{"RS256 no kid with one key", config.Auth{JWKSFile: single}, sign(t, jwt.SigningMethodRS256, k1, "", claims(nil)), "user-1", nil},
+		{"RS256 stranger's key", rs, sign(t, jwt.SigningMethodRS256, stranger, "k1", claims(nil)), "", ErrInvalidToken},
+		{"both configured, HS256", config.Auth{HS256Secret: testSecret, JWKSFile: jwks}, sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", claims(nil)), "user-1", nil},
+		{"both configured, RS256", config.Auth{HS256Secret: testSecret, JWKSFile: jwks}, sign(t, jwt.SigningMethodRS256, k1, "k1", claims(nil)), "user-1", nil},
+	}
+	for _, tt := range tests {
+		v, err := NewVerifier(tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: NewVerifier() = %v", tt.name, err)
+		}
+		p, err := v.Verify(tt.token)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: Verify() error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && (p.Owner != tt.owner || p.Subject != "user-1") {
+			t.Errorf("%s: Verify() = %+v, want owner %q with subject user-1", tt.name, p, tt.owner)
+		}
+	}
+}
+
+func TestNewVerifierRejectsBadKeys(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(data), 0o600)
+		return path
+	}
+	tests := []struct {
+		name string
+		cfg  config.Auth
+	}{
+		{"no keys", config.Auth{}},
+		{"missing file", config.Auth{JWKSFile: filepath.Join(dir, "missing.json")}},
+		{"not JSON", config.Auth{JWKSFile: write("bad.json", "{")}},
+		{"no RS256 keys", config.Auth{JWKSFile: write("ec.json", `{"keys":[{"kty":"EC","kid":"ec"}]}`)}},
+		{"bad modulus", config.Auth{JWKSFile: write("modulus.json", `{"keys":[{"kty":"RSA","kid":"k","n":"!!","e":"AQAB"}]}`)}},
+	}
+	for _, tt := range tests {
+		if _, err := NewVerifier(tt.cfg); err == nil {
+			t.Errorf("%s: NewVerifier() succeeded", tt.name)
+		}
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{"Bearer abc.def.ghi", "abc.def.ghi", true},
+		{"bearer abc", "abc", true},
+		{"BEARER  abc ", "abc", true},
+		{"Bearer ", "", false},
+		{"Basic dXNlcjpwYXNz", "", false},
+		{"abc.def.ghi", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		token, ok := BearerToken(tt.header)
+		if token != tt.token || ok != tt.ok {
+			t.Errorf("BearerToken(%q) = %q, %v; want %q, %v", tt.header, token, ok, tt.token, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package calendar does the date math behind refund ETAs: US federal
+// holidays, business days, and when a sent refund reaches the taxpayer.
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DeliveryRules say how long a sent refund takes to arrive
+type DeliveryRules struct {
+	// DepositDays is how many business days a direct deposit takes to land
+	// after Treasury releases it
+	DepositDays int
+	// CheckMailDays is how many mail delivery days (Monday to Saturday, except
+	// holidays) a paper check spends in the mail
+	CheckMailDays int
+}
+
+// DefaultDeliveryRules follow Treasury's ACH settlement and typical
+// first-class mail times
+var DefaultDeliveryRules = DeliveryRules{DepositDays: 3, CheckMailDays: 5}
+
+// holiday is a federal holiday with the rule for its date in a given year
+type holiday struct {
+	name string
+	date func(year int) time.Time
+}
+
+// federalHolidays are the eleven US federal holidays, which the Federal
+// Reserve also observes, so no ACH deposits settle on them
+var federalHolidays = []holiday{
+	{"new_years_day", fixed(time.January, 1)},
+	{"mlk_day", nthWeekday(time.January, time.Monday, 3)},
+	{"presidents_day", nthWeekday(time.February, time.Monday, 3)},
+	{"memorial_day", lastWeekday(time.May, time.Monday)},
+	{"juneteenth", fixed(time.June, 19)},
+	{"independence_day", fixed(time.July, 4)},
+	{"labor_day", nthWeekday(time.September, time.Monday, 1)},
+	{"columbus_day", nthWeekday(time.October, time.Monday, 2)},
+	{"veterans_day", fixed(time.November, 11)},
+	{"thanksgiving", nthWeekday(time.November, time.Thursday, 4)},
+	{"christmas", fixed(time.December, 25)},
+}
+
+// HolidayNames lists every federal holiday a calendar can observe
+func HolidayNames() []string {
+	names := make([]string, len(federalHolidays))
+	for i, h := range federalHolidays {
+		names[i] = h.name
+	}
+	return names
+}
+
+// Calendar knows which days are business days and when refunds arrive
+type Calendar struct {
+	holidays []holiday
+	closures map[time.Time]bool
+	rules    DeliveryRules
+}
+
+// Default observes every federal holiday with the default delivery rules
+func Default() *Calendar {
+	return &Calendar{holidays: federalHolidays, closures: map[time.Time]bool{}, rules: DefaultDeliveryRules}
+}
+
+// New returns a calendar observing the named federal holidays, plus closures
+// given as YYYY-MM-DD dates for one-off days off such as a day of mourning
+func New(holidays, closures []string, rules DeliveryRules) (*Calendar, error) {
+	var problems []string
+	c := &Calendar{closures: make(map[time.Time]bool, len(closures)), rules: rules}
+	for _, name := range holidays {
+		h, ok := lookupHoliday(name)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown holiday %q (known: %s)", name, strings.Join(HolidayNames(), ", ")))
+			continue
+		}
+		c.holidays = append(c.holidays, h)
+	}
+	for _, raw := range closures {
+		t, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("closure %q is not a YYYY-MM-DD date", raw))
+			continue
+		}
+		c.closures[day(t)] = true
+	}
+	if rules.DepositDays < 0 || rules.CheckMailDays < 0 {
+		problems = append(problems, "delivery days must not be negative")
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("calendar: %s", strings.Join(problems, "; "))
+	}
+	return c, nil
+}
+
+func lookupHoliday(name string) (holiday, bool) {
+	for _, h := range federalHolidays {
+		if h.name == name {
+			return h, true
+		}
+	}
+	return holiday{}, false
+}
+
+// Holiday returns the name of the holiday or closure observed on t's date
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	d := day(t)
+	if c.closures[d] {
+		return "closure", true
+	}
+	for _, h := range c.holidays {
+		if observed(h.date(d.Year())).Equal(d) {
+			return h.name, true
+		}
+	}
+	return "", false
+}
+
+// IsBusinessDay reports whether t falls on a weekday that is not a holiday
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if wd := t.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	_, holiday := c.Holiday(t)
+	return !holiday
+}
+
+// NextBusinessDay returns t's date if it is a business day, otherwise the
+// first business day after it
+func (c *Calendar) NextBusinessDay(t time.Time) time.Time {
+	d := day(t)
+	for !c.IsBusinessDay(d) {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// AddBusinessDays returns the date n business days after t
+func (c *Calendar) AddBusinessDays(t time.Time, n int) time.Time {
+	d := day(t)
+	for n > 0 {
+		d = d.AddDate(0, 0, 1)
+		if c.IsBusinessDay(d) {
+			n--
+		}
+	}
+	return d
+}
+
+// BusinessDaysBetween counts the business days after from up to and
+// including to, or zero if to is not after from
+func (c *Calendar) BusinessDaysBetween(from, to time.Time) int {
+	n := 0
+	for d, end := day(from).AddDate(0, 0, 1), day(to); !d.After(end); d = d.AddDate(0, 0, 1) {
+		if c.IsBusinessDay(d) {
+			n++
+		}
+	}
+	return n
+}
+
+// DepositDate is when a direct deposit sent at sent lands: Treasury releases
+// it on a business day and it settles DepositDays business days later
+func (c *Calendar) DepositDate(sent time.Time) time.Time {
+	return c.AddBusinessDays(c.NextBusinessDay(sent), c.rules.DepositDays)
+}
+
+// CheckDate is when a paper check sent at sent arrives: it is mailed on a
+// business day and spends CheckMailDays delivery days in the mail
+func (c *Calendar) CheckDate(sent time.Time) time.Time {
+	d := c.NextBusinessDay(sent)
+	for n := c.rules.CheckMailDays; n > 0; {
+		d = d.AddDate(0, 0, 1)
+		if c.isMailDay(d) {
+			n--
+		}
+	}
+	return d
+}
+
+// isMailDay reports whether the Postal Service delivers on t: every day but
+// Sunday and holidays
+func (c *Calendar) isMailDay(t time.Time) bool {
+	if t.Weekday() == time.Sunday {
+		return false
+	}
+	_, holiday := c.Holiday(t)
+	return !holiday
+}
+
+// observed moves a holiday falling on a Sunday to the Monday. Holidays on a
+// Saturday are not moved: the Federal Reserve stays open the Friday before.
+func observed(d time.Time) time.Time {
+	if d.Weekday() == time.Sunday {
+		return d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+func fixed(month time.Month, d int) func(int) time.Time {
+	return func(year int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// nthWeekday is the nth weekday wd of month, e.g. the third Monday
+func nthWeekday(month time.Month, wd time.Weekday, n int) func(int) time.Time {
+	return func(year int) time.Time {
+		first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		offset := (int(wd) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, offset+7*(n-1))
+	}
+}
+
+// lastWeekday is the last weekday wd of month, e.g. the last Monday
+func lastWeekday(month time.Month, wd time.Weekday) func(int) time.Time {
+	return func(year int) time.Time {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		offset := (int(last.Weekday()) - int(wd) + 7) % 7
+		return last.AddDate(0, 0, -offset)
+	}
+}
+
+// day truncates t to the start of its day in UTC, keeping the calendar date
+// t has in its own zone
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,137 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestHolidays(t *testing.T) {
+	c := Default()
+	tests := []struct {
+		date     time.Time
+		holiday  string
+		business bool
+	}{
+		// Saturday holidays are not moved; the Friday before stays open
+		{date(2026, time.July, 3), "", true},
+		{date(2026, time.July, 4), "independence_day", false},
+		// Sunday holidays are observed on the Monday
+		{date(2027, time.July, 4), "", false},
+		{date(2027, time.July, 5), "independence_day", false},
+		{date(2023, time.January, 2), "new_years_day", false},
+		{date(2022, time.December, 26), "christmas", false},
+		{date(2022, time.June, 20), "juneteenth", false},
+		{date(2021, time.December, 24), "", true},
+		{date(2027, time.December, 31), "", true},
+		// Holidays set by weekday
+		{date(2026, time.January, 19), "mlk_day", false},
+		{date(2026, time.February, 16), "presidents_day", false},
+		{date(2026, time.May, 25), "memorial_day", false},
+		{date(2026, time.September, 7), "labor_day", false},
+		{date(2026, time.October, 12), "columbus_day", false},
+		{date(2026, time.November, 26), "thanksgiving", false},
+		{date(2026, time.November, 11), "veterans_day", false},
+		// Ordinary days
+		{date(2026, time.March, 2), "", true},
+		{date(2026, time.March, 7), "", false},
+		{date(2026, time.March, 8), "", false},
+	}
+	for _, tt := range tests {
+		name, ok := c.Holiday(tt.date)
+		if name != tt.holiday || ok != (tt.holiday != "") {
+			t.Errorf("Holiday(%s) = %q, %v; want %q", tt.date.Format("Mon 2006-01-02"), name, ok, tt.holiday)
+		}
+		if got := c.IsBusinessDay(tt.date); got != tt.business {
+			t.Errorf("IsBusinessDay(%s) = %v, want %v", tt.date.Format("Mon 2006-01-02"), got, tt.business)
+		}
+	}
+}
+
+func TestNewObservesOnlyNamedHolidays(t *testing.T) {
+	c, err := New([]string{"christmas"}, []string{"2026-03-02"}, DefaultDeliveryRules)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	if !c.IsBusinessDay(date(2027, time.July, 5)) {
+		t.Error("observed Independence Day is off with only christmas configured")
+	}
+	if c.IsBusinessDay(date(2026, time.December, 25)) {
+		t.Error("christmas is a business day")
+	}
+	if name, ok := c.Holiday(date(2026, time.March, 2)); !ok || name != "closure" {
+		t.Errorf("Holiday(closure) = %q, %v; want closure", name, ok)
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		holidays []string
+		closures []string
+		rules    DeliveryRules
+	}{
+		{"unknown holiday", []string{"festivus"}, nil, DefaultDeliveryRules},
+		{"bad closure", nil, []string{"03/02/2026"}, DefaultDeliveryRules},
+		{"negative days", nil, nil, DeliveryRules{DepositDays: -1}},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.holidays, tt.closures, tt.rules); err == nil {
+			t.Errorf("%s: New() succeeded", tt.name)
+		}
+	}
+}
+
+func TestAddBusinessDays(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	c := Default()
+	tests := []struct {
+		name  string
+		start time.Time
+		n     int
+		want  time.Time
+	}{
+		{"zero", date(2026, time.March, 4), 0, date(2026, time.March, 4)},
+		{"within the week", date(2026, time.March, 2), 3, date(2026, time.March, 5)},
+		{"over a weekend", date(2026, time.March, 6), 1, date(2026, time.March, 9)},
+		{"from a Saturday", date(2026, time.March, 7), 1, date(2026, time.March, 9)},
+		{"over a Sunday holiday", date(2027, time.July, 2), 1, date(2027, time.July, 6)},
+		{"over Christmas", date(2026, time.December, 24), 1, date(2026, time.December, 28)},
+		{"into the new year", date(2026, time.December, 31), 1, date(2027, time.January, 4)},
+		{"across the new year", date(2026, time.December, 30), 4, date(2027, time.January, 6)},
+		{"new year on a Saturday", date(2027, time.December, 31), 1, date(2028, time.January, 3)},
+		{"new year on a Thursday", date(2025, time.December, 31), 2, date(2026, time.January, 5)},
+		{"local date is kept", time.Date(2026, time.March, 6, 22, 30, 0, 0, ny), 1, date(2026, time.March, 9)},
+	}
+	for _, tt := range tests {
+		if got := c.AddBusinessDays(tt.start, tt.n); !got.Equal(tt.want) {
+			t.Errorf("%s: AddBusinessDays(%s, %d) = %s, want %s", tt.name,
+				tt.start.Format("Mon 2006-01-02"), tt.n, got.Format("Mon 2006-01-02"), tt.want.Format("Mon 2006-01-02"))
+		}
+	}
+}
+
+func TestBusinessDaysBetween(t *testing.T) {
+	c := Default()
+	if got := c.BusinessDaysBetween(date(2026, time.December, 24), date(2027, time.January, 4)); got != 5 {
+		t.Errorf("BusinessDaysBetween(Dec 24, Jan 4) = %d, want 5", got)
+	}
+	if got := c.BusinessDaysBetween(date(2026, time.March, 9), date(2026, time.March, 2)); got != 0 {
+		t.Errorf("BusinessDaysBetween with to before from = %d, want 0", got)
+	}
+}
+
+func TestDeliveryDates(t *testing.T) {
+	c := Default()
+	// Sent on Independence Day: released Monday, settles three business days later
+	if got := c.DepositDate(date(2026, time.July, 4)); !got.Equal(date(2026, time.July, 9)) {
+		t.Errorf("DepositDate(Jul 4) = %s, want 2026-07-09", got.Format(time.DateOnly))
+	}
+	// Mailed Christmas Eve: no delivery on Christmas or Sunday, Saturday counts
+	if got := c.CheckDate(date(2026, time.December, 24)); !got.Equal(date(2026, time.December, 31)) {
+		t.Errorf("CheckDate(Dec 24) = %s, want 2026-12-31", got.Format(time.DateOnly))
+	}
+}
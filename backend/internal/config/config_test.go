@@ -0,0 +1,145 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func loadTest(t *testing.T, yaml string, args ...string) *Config {
+	t.Helper()
+	if yaml != "" {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		args = append([]string{"-config", path}, args...)
+	}
+	cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	return cfg
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("PORT", "")
+	t.Setenv("LLM_MAX_TOKENS", "")
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("DRAIN_DELAY", "")
+
+	const file = `
+server:
+  port: 9000
+  drain_delay: 1s
+llm:
+  max_tokens: 300
+logging:
+  level: warn
+`
+	tests := []struct {
+		name  string
+		yaml  string
+		env   map[string]string
+		args  []string
+		check func(*Config) bool
+	}{
+		{"defaults", "", nil, nil, func(c *Config) bool {
+			return c.Server.Port == 8080 && c.LLM.MaxTokens == 200 && c.Logging.Level == "info"
+		}},
+		{"yaml over defaults", file, nil, nil, func(c *Config) bool {
+			return c.Server.Port == 9000 && c.LLM.MaxTokens == 300 && c.Logging.Level == "warn" && c.Server.DrainDelay == time.Second
+		}},
+		{"yaml keeps unset defaults", file, nil, nil, func(c *Config) bool {
+			return c.Server.ShutdownTimeout == 25*time.Second && c.Database.MaxOpenConns == 25
+		}},
+		{"env over yaml", file, map[string]string{"PORT": "9100", "LOG_LEVEL": "debug"}, nil, func(c *Config) bool {
+			return c.Server.Port == 9100 && c.Logging.Level == "debug" && c.LLM.MaxTokens == 300
+		}},
+		{"flags over env", file, map[string]string{"PORT": "9100", "LLM_MAX_TOKENS": "400"}, []string{"-port", "9200"}, func(c *Config) bool {
+			return c.Server.Port == 9200 && c.LLM.MaxTokens == 400
+		}},
+		{"empty env is ignored", file, map[string]string{"PORT": ""}, nil, func(c *Config) bool {
+			return c.Server.Port == 9000
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if cfg := loadTest(t, tt.yaml, tt.args...); !tt.check(cfg) {
+				t.Fatalf("unexpected config: port %d, max tokens %d, log level %q", cfg.Server.Port, cfg.LLM.MaxTokens, cfg.Logging.Level)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("PORT", "eighty")
+	if _, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil); err == nil {
+		t.Fatal("Load() accepted PORT=eighty")
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"postgres://demo:s3cret@db:5432/demo?sslmode=disable", "postgres://demo:xxxxx@db:5432/demo?sslmode=disable"},
+		{"postgres://demo@db:5432/demo", "postgres://demo@db:5432/demo"},
+		{"postgres://db:5432/demo?password=s3cret&sslmode=disable", "postgres://db:5432/demo?password=xxxxx&sslmode=disable"},
+		{"host=db user=demo password=s3cret dbname=demo sslmode=disable", "host=db user=demo password=xxxxx dbname=demo sslmode=disable"},
+		{"host=db password = s3cret dbname=demo", "host=db password = xxxxx dbname=demo"},
+		{"host=db password='with space\\'s' dbname=demo", "host=db password=xxxxx dbname=demo"},
+		{"host=db PASSWORD=s3cret", "host=db PASSWORD=xxxxx"},
+		{"host=db user=demo", "host=db user=demo"},
+	}
+	for _, tt := range tests {
+		if got := redactDSN(tt.dsn); got != tt.want {
+			t.Errorf("redactDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestRedactedHidesSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Database.DSN = "host=db password=s3cret"
+	cfg.LLM.APIKey = "sk-test"
+
+	out := cfg.Redacted()
+	if out["database.dsn"] != "host=db password=xxxxx" {
+		t.Errorf("database.dsn = %q", out["database.dsn"])
+	}
+	if out["llm.api_key"] != "[REDACTED]" {
+		t.Errorf("llm.api_key = %q", out["llm.api_key"])
+	}
+	if out["auth.hs256_secret"] != "" {
+		t.Errorf("unset secret shown as %q, want empty", out["auth.hs256_secret"])
+	}
+}
+
+func TestValidateTrustedProxies(t *testing.T) {
+	tests := []struct {
+		proxies []string
+		ok      bool
+	}{
+		{nil, true},
+		{[]string{"172.28.0.10", "10.0.0.0/8", "::1"}, true},
+		{[]string{"frontend"}, false},
+		{[]string{"10.0.0.0/33"}, false},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.Auth.Disabled = true
+		cfg.Server.TrustedProxies = tt.proxies
+		if err := cfg.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate() with trusted proxies %v = %v, want ok %v", tt.proxies, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"refund-demo/internal/config"
+	"refund-demo/internal/scraper"
+	"refund-demo/internal/store"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// DatabaseCheck pings Postgres and reports pool usage
+func DatabaseCheck(db *store.DB) Check {
+	return Check{
+		Name:     "database",
+		Critical: true,
+		Run: func(ctx context.Context) Result {
+			stats := db.Stats()
+			details := map[string]interface{}{
+				"open_connections": stats.OpenConnections,
+				"in_use":           stats.InUse,
+				"max_open":         stats.MaxOpenConnections,
+			}
+			if err := db.PingContext(ctx); err != nil {
+				return Fail(err, details)
+			}
+			return OK(details)
+		},
+	}
+}
+
+// MigrationCheck fails when the schema is dirty or no longer at the version
+// the server migrated to at startup
+func MigrationCheck(db *store.DB, expected store.MigrationState) Check {
+	return Check{
+		Name:     "migrations",
+		Critical: true,
+		Run: func(ctx context.Context) Result {
+			current, err := store.CurrentMigration(ctx, db)
+			if err != nil {
+				return Fail(err, nil)
+			}
+			details := map[string]interface{}{
+				"version":  current.Version,
+				"dirty":    current.Dirty,
+				"expected": expected.Version,
+			}
+			switch {
+			case current.Dirty:
+				return Fail(fmt.Errorf("migration %d is dirty", current.Version), details)
+			case !current.Applied || current.Version < expected.Version:
+				return Fail(errors.New("schema is behind the version this server requires"), details)
+			}
+			return OK(details)
+		},
+	}
+}
+
+// SchedulerCheck reports whether the background job schedule is active. A stopped
+// scheduler degrades rather than fails, since requests are still served.
+func SchedulerCheck(s *scraper.Scheduler) Check {
+	return Check{
+		Name: "scheduler",
+		Run: func(ctx context.Context) Result {
+			if !s.Running() {
+				return Degraded("scraper scheduler is not running", nil)
+			}
+			return OK(map[string]interface{}{"next_run": s.NextRun(), "jobs": s.NextRuns()})
+		},
+	}
+}
+
+// LLMCheck reports whether the explanation model is reachable. Without an API
+// key explanations use canned demo text, which is reported as degraded.
+func LLMCheck(llm config.LLM) Check {
+	details := map[string]interface{}{"model": llm.Model}
+	return Check{
+		Name: "llm",
+		// Provider calls are slow and rate limited, so cache results longer
+		TTL: time.Minute,
+		Run: func(ctx context.Context) Result {
+			if llm.APIKey == "" {
+				return Degraded("no API key configured, using demo explanations", details)
+			}
+			client := openai.NewClient(llm.APIKey)
+			if _, err := client.GetModel(ctx, llm.Model); err != nil {
+				return Fail(err, details)
+			}
+			return OK(details)
+		},
+	}
+}
@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of a check or of a whole report
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded"
+	StatusFail     Status = "fail"
+)
+
+// Result is the outcome of a single check run
+type Result struct {
+	Status     Status                 `json:"status"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	CheckedAt  time.Time              `json:"checked_at"`
+	DurationMS int64                  `json:"duration_ms"`
+	Cached     bool                   `json:"cached"`
+}
+
+// OK reports a passing check with optional details
+func OK(details map[string]interface{}) Result {
+	return Result{Status: StatusOK, Details: details}
+}
+
+// Degraded reports a check that works in a reduced mode
+func Degraded(reason string, details map[string]interface{}) Result {
+	return Result{Status: StatusDegraded, Error: reason, Details: details}
+}
+
+// Fail reports a failing check
+func Fail(err error, details map[string]interface{}) Result {
+	return Result{Status: StatusFail, Error: err.Error(), Details: details}
+}
+
+// Check is a named probe of one dependency
+type Check struct {
+	Name string
+	// Critical checks fail the report; a non-critical failure only degrades it
+	Critical bool
+	// Timeout bounds a single run
+	Timeout time.Duration
+	// TTL is how long a result is reused so frequent probes don't hammer the
+	// dependency; zero uses the checker default
+	TTL time.Duration
+	Run func(ctx context.Context) Result
+}
+
+// Report is the combined result of every check
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs a fixed set of checks concurrently and caches their results
+type Checker struct {
+	ttl     time.Duration
+	timeout time.Duration
+	entries []*entry
+}
+
+type entry struct {
+	check Check
+
+	// mu also collapses concurrent probes into a single run
+	mu      sync.Mutex
+	last    Result
+	expires time.Time
+}
+
+// NewChecker creates a checker whose checks default to ttl and timeout
+func NewChecker(ttl, timeout time.Duration, checks ...Check) *Checker {
+	c := &Checker{ttl: ttl, timeout: timeout}
+	for _, check := range checks {
+		c.Add(check)
+	}
+	return c
+}
+
+// Add registers a check; it must be called before the checker is in use
+func (c *Checker) Add(check Check) {
+	if check.TTL == 0 {
+		check.TTL = c.ttl
+	}
+	if check.Timeout == 0 {
+		check.Timeout = c.timeout
+	}
+	c.entries = append(c.entries, &entry{check: check})
+}
+
+// Run executes every check, reusing cached results that have not expired
+func (c *Checker) Run(ctx context.Context) Report {
+	results := make([]Result, len(c.entries))
+	var wg sync.WaitGroup
+	for i, e := range c.entries {
+		wg.Add(1)
+		go func(i int, e *entry) {
+			defer wg.Done()
+			results[i] = e.result(ctx)
+		}(i, e)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.entries))}
+	for i, e := range c.entries {
+		r := results[i]
+		report.Checks[e.check.Name] = r
+		switch {
+		case r.Status == StatusFail && e.check.Critical:
+			report.Status = StatusFail
+		case r.Status != StatusOK && report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func (e *entry) result(ctx context.Context) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(e.expires) {
+		cached := e.last
+		cached.Cached = true
+		return cached
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, e.check.Timeout)
+	defer cancel()
+
+	r := e.run(runCtx)
+	if r.Status == StatusFail && runCtx.Err() == context.DeadlineExceeded {
+		r.Error = fmt.Sprintf("timed out after %s: %s", e.check.Timeout, r.Error)
+	}
+	r.CheckedAt = now
+	r.DurationMS = time.Since(now).Milliseconds()
+
+	e.last = r
+	e.expires = now.Add(e.check.TTL)
+	return r
+}
+
+// run calls the check, turning a panic into a failed result
+func (e *entry) run(ctx context.Context) (r Result) {
+	defer func() {
+		if p := recover(); p != nil {
+			r = Fail(fmt.Errorf("check panicked: %v", p), nil)
+		}
+	}()
+	return e.check.Run(ctx)
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"refund-demo/internal/config"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// current is the configuration applied by the last Setup
+var current config.Logging
+
+// Setup configures the global zerolog logger from cfg. Loggers stored in a
+// context inherit its output, level and redaction.
+func Setup(cfg config.Logging) error {
+	level, err := zerolog.ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(level)
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+
+	var out io.Writer = os.Stderr
+	if cfg.Format == "console" {
+		out = zerolog.ConsoleWriter{Out: os.Stderr}
+	}
+	// Redaction rewrites the JSON events before the console writer formats them
+	if fields := redactedFields(cfg); len(fields) > 0 {
+		out = &redactingWriter{out: out, fields: fields}
+	}
+
+	log.Logger = zerolog.New(out).With().Timestamp().Logger()
+	// Code without a request context still logs through the global logger
+	zerolog.DefaultContextLogger = &log.Logger
+	current = cfg
+	return nil
+}
+
+// Ctx returns the logger stored in ctx, or the global logger
+func Ctx(ctx context.Context) *zerolog.Logger {
+	return zerolog.Ctx(ctx)
+}
+
+// WithReturnID returns a context whose logger also carries return_id
+func WithReturnID(ctx context.Context, returnID string) context.Context {
+	if returnID == "" {
+		return ctx
+	}
+	logger := zerolog.Ctx(ctx).With().Str("return_id", returnID).Logger()
+	return logger.WithContext(ctx)
+}
+
+// withTrace adds the trace and span IDs from ctx so log lines can be joined
+// with traces
+func withTrace(ctx context.Context, lc zerolog.Context) zerolog.Context {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return lc
+	}
+	return lc.Str("trace_id", sc.TraceID().String()).Str("span_id", sc.SpanID().String())
+}
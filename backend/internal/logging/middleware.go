@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"time"
+
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// HeaderRequestID carries the request ID in both directions
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength bounds IDs accepted from callers
+const maxRequestIDLength = 128
+
+// Middleware assigns each request an ID, honoring a well-formed incoming
+// X-Request-ID, echoes it on the response, and stores a logger carrying it in
+// the request context. It also writes one access log line per request.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		id := c.Get(HeaderRequestID)
+		if !validRequestID(id) {
+			id = store.NewULID()
+		}
+		c.Set(HeaderRequestID, id)
+		c.Locals("request_id", id)
+
+		ctx := c.UserContext()
+		logger := withTrace(ctx, log.Logger.With().Str("request_id", id)).Logger()
+		c.SetUserContext(logger.WithContext(ctx))
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if fe, ok := err.(*fiber.Error); ok {
+			status = fe.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		var event *zerolog.Event
+		switch {
+		case status >= 500:
+			event = logger.Error().Err(err)
+		case status >= 400:
+			event = logger.Warn()
+		default:
+			event = logger.Info()
+		}
+		event = event.Str("method", c.Method())
+		// Paths embed return IDs; the route template is enough when IDs are redacted
+		if !current.RedactIDs {
+			event = event.Str("path", c.Path())
+		}
+		event.
+			Str("route", c.Route().Path).
+			Int("status", status).
+			Dur("latency", time.Since(start)).
+			Str("ip", c.IP()).
+			Msg("request")
+		return err
+	}
+}
+
+// validRequestID accepts printable ASCII IDs of reasonable length so callers
+// can't inject control characters into logs or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMiddlewareRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		echoed   bool
+	}{
+		{name: "echoes incoming", incoming: "req-abc-123", echoed: true},
+		{name: "generates when missing", incoming: ""},
+		{name: "replaces control characters", incoming: "bad\tid"},
+		{name: "replaces overlong", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(Middleware())
+			var local string
+			app.Get("/ping", func(c *fiber.Ctx) error {
+				local, _ = c.Locals("request_id").(string)
+				return c.SendStatus(fiber.StatusNoContent)
+			})
+
+			req := httptest.NewRequest("GET", "/ping", nil)
+			if tt.incoming != "" {
+				req.Header.Set(HeaderRequestID, tt.incoming)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			resp.Body.Close()
+
+			got := resp.Header.Get(HeaderRequestID)
+			if got == "" {
+				t.Fatal("response has no request ID")
+			}
+			if got != local {
+				t.Errorf("response ID %q differs from the handler's %q", got, local)
+			}
+			if tt.echoed && got != tt.incoming {
+				t.Errorf("request ID = %q, want %q echoed", got, tt.incoming)
+			}
+			if !tt.echoed && got == tt.incoming {
+				t.Errorf("request ID %q was echoed, want a generated one", got)
+			}
+		})
+	}
+}
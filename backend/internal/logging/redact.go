@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"refund-demo/internal/config"
+)
+
+// idFields identify a taxpayer or their return
+var idFields = []string{"return_id", "filing_id", "owner_id", "owner", "subject"}
+
+// amountFields hold money values
+var amountFields = []string{"amount", "amount_cents", "refund_amount", "refund_amount_cents"}
+
+type redaction int
+
+const (
+	redactHash redaction = iota
+	redactMask
+)
+
+func redactedFields(cfg config.Logging) map[string]redaction {
+	fields := make(map[string]redaction)
+	if cfg.RedactIDs {
+		for _, f := range idFields {
+			fields[f] = redactHash
+		}
+	}
+	if cfg.RedactAmounts {
+		for _, f := range amountFields {
+			fields[f] = redactMask
+		}
+	}
+	return fields
+}
+
+// redactingWriter rewrites configured top-level fields of each JSON log event.
+// IDs become a short stable hash so lines for the same return can still be
+// correlated; amounts are masked outright.
+type redactingWriter struct {
+	out    io.Writer
+	fields map[string]redaction
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(p, &event); err != nil {
+		// Not a JSON event; pass it through untouched
+		return w.out.Write(p)
+	}
+
+	changed := false
+	for key, how := range w.fields {
+		raw, ok := event[key]
+		if !ok {
+			continue
+		}
+		event[key] = redactValue(raw, how)
+		changed = true
+	}
+	if !changed {
+		return w.out.Write(p)
+	}
+
+	// zerolog's ConsoleWriter and log shippers expect one event per line
+	out, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	out = append(out, '\n')
+	if _, err := w.out.Write(out); err != nil {
+		return 0, err
+	}
+	// Report the original length so zerolog doesn't treat this as a short write
+	return len(p), nil
+}
+
+func redactValue(raw json.RawMessage, how redaction) json.RawMessage {
+	if how == redactMask {
+		return json.RawMessage(`"[REDACTED]"`)
+	}
+	sum := sha256.Sum256(bytes.Trim(raw, `"`))
+	return json.RawMessage(fmt.Sprintf(`"h:%s"`, hex.EncodeToString(sum[:4])))
+}
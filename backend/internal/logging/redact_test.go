@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"refund-demo/internal/config"
+
+	"github.com/rs/zerolog"
+)
+
+func TestRedactingWriter(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Logging
+		want map[string]string
+	}{
+		{
+			name: "ids hashed",
+			cfg:  config.Logging{RedactIDs: true},
+			want: map[string]string{"return_id": redactedID("RET-123"), "amount_cents": "125000"},
+		},
+		{
+			name: "amounts masked",
+			cfg:  config.Logging{RedactAmounts: true},
+			want: map[string]string{"return_id": "RET-123", "amount_cents": "[REDACTED]"},
+		},
+		{
+			name: "both",
+			cfg:  config.Logging{RedactIDs: true, RedactAmounts: true},
+			want: map[string]string{"return_id": redactedID("RET-123"), "amount_cents": "[REDACTED]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := zerolog.New(&redactingWriter{out: &buf, fields: redactedFields(tt.cfg)})
+			logger.Info().Str("return_id", "RET-123").Int("amount_cents", 125000).Msg("refund")
+
+			event := decodeEvent(t, buf.String())
+			for key, want := range tt.want {
+				if got := event[key]; got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+			if event["message"] != "refund" {
+				t.Errorf("message = %q, want it untouched", event["message"])
+			}
+		})
+	}
+}
+
+func TestRedactingWriterHashIsStable(t *testing.T) {
+	a, b := redactedID("RET-123"), redactedID("RET-456")
+	if a != redactedID("RET-123") {
+		t.Error("same return_id hashed differently")
+	}
+	if a == b {
+		t.Error("different return_ids hashed the same")
+	}
+	if !strings.HasPrefix(a, "h:") || strings.Contains(a, "RET-123") {
+		t.Errorf("hash %q leaks or lacks its prefix", a)
+	}
+}
+
+func TestRedactingWriterPassesNonJSON(t *testing.T) {
+	var buf bytes.Buffer
+	w := &redactingWriter{out: &buf, fields: redactedFields(config.Logging{RedactIDs: true})}
+	line := "return_id=RET-123 plain text\n"
+	n, err := w.Write([]byte(line))
+	if err != nil || n != len(line) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if buf.String() != line {
+		t.Errorf("output = %q, want it unchanged", buf.String())
+	}
+}
+
+// redactedID logs id through the redacting writer and returns what it became
+func redactedID(id string) string {
+	var buf bytes.Buffer
+	w := &redactingWriter{out: &buf, fields: redactedFields(config.Logging{RedactIDs: true})}
+	logger := zerolog.New(w)
+	logger.Info().Str("return_id", id).Send()
+	var event map[string]string
+	_ = json.Unmarshal(buf.Bytes(), &event)
+	return event["return_id"]
+}
+
+func decodeEvent(t *testing.T, line string) map[string]string {
+	t.Helper()
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		t.Fatalf("output %q is not JSON: %v", line, err)
+	}
+	event := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			event[k] = v
+		default:
+			b, _ := json.Marshal(v)
+			event[k] = string(b)
+		}
+	}
+	return event
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"refund-demo/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "refund"
+
+// Stream outcomes recorded by StreamFinished
+const (
+	OutcomeCompleted   = "completed"
+	OutcomeInterrupted = "interrupted"
+	OutcomeClientGone  = "client_gone"
+	OutcomeError       = "error"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to produce HTTP response headers. Streamed bodies are measured by stream_duration_seconds.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	streamsActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "streams_active",
+		Help:      "SSE streams currently open.",
+	}, []string{"stream"})
+
+	streamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stream_duration_seconds",
+		Help:      "SSE stream lifetimes by outcome.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600},
+	}, []string{"stream", "outcome"})
+
+	llmDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "LLM completion latency from request to end of stream.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"model", "outcome"})
+
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens reported by the LLM provider.",
+	}, []string{"model", "type"})
+
+	llmErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_errors_total",
+		Help:      "LLM failures by stage (connect or stream).",
+	}, []string{"model", "stage"})
+
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Scheduled job run time by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"job", "outcome"})
+
+	jobFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_failures_total",
+		Help:      "Scheduled job runs that returned an error.",
+	}, []string{"job"})
+)
+
+// Handler serves the Prometheus exposition format
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
+
+// RegisterDB exports connection pool stats and the returns-by-status gauge
+func RegisterDB(db *store.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db.DB.DB, "postgres"))
+	prometheus.MustRegister(newReturnsCollector(db))
+}
+
+// Middleware records request counts and latencies. Routes are labelled by
+// their template (/v1/status/:id) to keep cardinality bounded.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The app error handler hasn't written the status yet
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+
+		route := c.Route().Path
+		if route == "/" && c.Path() != "/" {
+			// Only the catch-all middleware matched
+			route = "unmatched"
+		}
+
+		labels := prometheus.Labels{"method": c.Method(), "route": route, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// StreamStarted marks an SSE stream open and returns a func that records its
+// duration and outcome when it ends
+func StreamStarted(stream string) func(outcome string) {
+	start := time.Now()
+	streamsActive.WithLabelValues(stream).Inc()
+	return func(outcome string) {
+		streamsActive.WithLabelValues(stream).Dec()
+		streamDuration.WithLabelValues(stream, outcome).Observe(time.Since(start).Seconds())
+	}
+}
+
+// LLMRequest records a finished completion and, when known, its token usage
+func LLMRequest(model, outcome string, elapsed time.Duration, promptTokens, completionTokens int) {
+	llmDuration.WithLabelValues(model, outcome).Observe(elapsed.Seconds())
+	if promptTokens > 0 {
+		llmTokens.WithLabelValues(model, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		llmTokens.WithLabelValues(model, "completion").Add(float64(completionTokens))
+	}
+}
+
+// LLMError counts a provider failure at the given stage
+func LLMError(model, stage string) {
+	llmErrors.WithLabelValues(model, stage).Inc()
+}
+
+// JobRun records a scheduled job run
+func JobRun(job string, elapsed time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+		jobFailures.WithLabelValues(job).Inc()
+	}
+	jobDuration.WithLabelValues(job, outcome).Observe(elapsed.Seconds())
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"refund-demo/internal/store"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// returnsCacheTTL keeps frequent scrapes from running the GROUP BY each time
+const returnsCacheTTL = 15 * time.Second
+
+// returnsCollector exports the number of returns in each status. Counts are
+// queried at scrape time so every replica reports the same values.
+type returnsCollector struct {
+	db   *store.DB
+	desc *prometheus.Desc
+
+	mu      sync.Mutex
+	counts  map[string]int
+	fetched time.Time
+}
+
+func newReturnsCollector(db *store.DB) *returnsCollector {
+	return &returnsCollector{
+		db: db,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "returns"),
+			"Returns by current status.",
+			[]string{"status"}, nil,
+		),
+	}
+}
+
+func (c *returnsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *returnsCollector) Collect(ch chan<- prometheus.Metric) {
+	counts := c.load()
+	if counts == nil {
+		return
+	}
+	// Report every status so absent ones read as zero rather than missing
+	for _, status := range store.Statuses {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(counts[status]), status)
+	}
+}
+
+func (c *returnsCollector) load() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts != nil && time.Since(c.fetched) < returnsCacheTTL {
+		return c.counts
+	}
+	counts, err := store.CountReturnsByStatus(context.Background(), c.db)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to count returns for metrics")
+		// Serve the stale counts rather than dropping the series
+		return c.counts
+	}
+	c.counts = counts
+	c.fetched = time.Now()
+	return counts
+}
@@ -0,0 +1,158 @@
+// Package money represents amounts as integer minor units with a currency so
+// refund math never goes through floating point
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// USD is the currency of every federal and state refund
+const USD = "USD"
+
+var (
+	// ErrCurrencyMismatch is returned when combining amounts in different currencies
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+
+	// ErrOverflow is returned when a sum doesn't fit in int64 cents
+	ErrOverflow = errors.New("money: amount overflows")
+)
+
+// symbols are the prefixes used when formatting well-known currencies
+var symbols = map[string]string{
+	USD:   "$",
+	"EUR": "€",
+	"GBP": "£",
+	"CAD": "CA$",
+}
+
+// Amount is a value in the minor unit (cents) of Currency
+type Amount struct {
+	Cents    int64
+	Currency string
+}
+
+// New returns an amount of cents in currency
+func New(cents int64, currency string) Amount {
+	return Amount{Cents: cents, Currency: currency}
+}
+
+// Dollars returns an amount in US cents
+func Dollars(cents int64) Amount {
+	return Amount{Cents: cents, Currency: USD}
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 code
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for i := 0; i < 3; i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Add returns a+b; both must share a currency
+func (a Amount) Add(b Amount) (Amount, error) {
+	if a.Currency != b.Currency {
+		return Amount{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, a.Currency, b.Currency)
+	}
+	if (b.Cents > 0 && a.Cents > math.MaxInt64-b.Cents) || (b.Cents < 0 && a.Cents < math.MinInt64-b.Cents) {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{Cents: a.Cents + b.Cents, Currency: a.Currency}, nil
+}
+
+// Sub returns a-b; both must share a currency
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if b.Cents == math.MinInt64 {
+		return Amount{}, ErrOverflow
+	}
+	return a.Add(Amount{Cents: -b.Cents, Currency: b.Currency})
+}
+
+// Neg returns the amount with its sign flipped
+func (a Amount) Neg() Amount {
+	return Amount{Cents: -a.Cents, Currency: a.Currency}
+}
+
+// Abs returns the amount without its sign
+func (a Amount) Abs() Amount {
+	if a.Cents < 0 {
+		return a.Neg()
+	}
+	return a
+}
+
+// IsZero reports whether the amount is zero
+func (a Amount) IsZero() bool {
+	return a.Cents == 0
+}
+
+// String formats the amount for people, e.g. "$5,000.00" or "-$12.50".
+// Currencies without a known symbol are suffixed with their code.
+func (a Amount) String() string {
+	sign := ""
+	cents := a.Cents
+	if cents < 0 {
+		sign = "-"
+	}
+	// Work in uint64 so the minimum int64 negates cleanly
+	u := uint64(cents)
+	if cents < 0 {
+		u = uint64(-(cents + 1)) + 1
+	}
+	whole := groupThousands(strconv.FormatUint(u/100, 10))
+	frac := fmt.Sprintf("%02d", u%100)
+
+	if symbol, ok := symbols[a.Currency]; ok {
+		return sign + symbol + whole + "." + frac
+	}
+	return sign + whole + "." + frac + " " + a.Currency
+}
+
+func groupThousands(digits string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	head := len(digits) % 3
+	if head == 0 {
+		head = 3
+	}
+	out := digits[:head]
+	for i := head; i < len(digits); i += 3 {
+		out += "," + digits[i:i+3]
+	}
+	return out
+}
+
+// amountJSON is the wire form; formatted is output only
+type amountJSON struct {
+	Cents     int64  `json:"cents"`
+	Currency  string `json:"currency"`
+	Formatted string `json:"formatted,omitempty"`
+}
+
+// MarshalJSON writes the cents, currency and a display string
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(amountJSON{Cents: a.Cents, Currency: a.Currency, Formatted: a.String()})
+}
+
+// UnmarshalJSON reads cents and currency, ignoring the display string
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var v amountJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if !ValidCurrency(v.Currency) {
+		return fmt.Errorf("money: invalid currency %q", v.Currency)
+	}
+	*a = Amount{Cents: v.Cents, Currency: v.Currency}
+	return nil
+}
@@ -0,0 +1,121 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAddSub(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      func(a, b Amount) (Amount, error)
+		a, b    Amount
+		want    Amount
+		wantErr error
+	}{
+		{"add", Amount.Add, Dollars(150), Dollars(-50), Dollars(100), nil},
+		{"sub", Amount.Sub, Dollars(150), Dollars(200), Dollars(-50), nil},
+		{"add to max", Amount.Add, Dollars(math.MaxInt64 - 1), Dollars(1), Dollars(math.MaxInt64), nil},
+		{"add past max", Amount.Add, Dollars(math.MaxInt64), Dollars(1), Amount{}, ErrOverflow},
+		{"add past min", Amount.Add, Dollars(math.MinInt64), Dollars(-1), Amount{}, ErrOverflow},
+		{"add min and max", Amount.Add, Dollars(math.MinInt64), Dollars(math.MaxInt64), Dollars(-1), nil},
+		{"sub to min", Amount.Sub, Dollars(math.MinInt64 + 1), Dollars(1), Dollars(math.MinInt64), nil},
+		{"sub past min", Amount.Sub, Dollars(math.MinInt64), Dollars(1), Amount{}, ErrOverflow},
+		{"sub past max", Amount.Sub, Dollars(math.MaxInt64), Dollars(-1), Amount{}, ErrOverflow},
+		{"sub min", Amount.Sub, Dollars(0), Dollars(math.MinInt64), Amount{}, ErrOverflow},
+		{"add mixed currencies", Amount.Add, Dollars(100), New(100, "EUR"), Amount{}, ErrCurrencyMismatch},
+		{"sub mixed currencies", Amount.Sub, Dollars(100), New(100, "EUR"), Amount{}, ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.op(tt.a, tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{Dollars(0), "$0.00"},
+		{Dollars(5), "$0.05"},
+		{Dollars(-5), "-$0.05"},
+		{Dollars(99999), "$999.99"},
+		{Dollars(100000), "$1,000.00"},
+		{Dollars(500000), "$5,000.00"},
+		{Dollars(-1250), "-$12.50"},
+		{Dollars(123456789), "$1,234,567.89"},
+		{Dollars(-123456789), "-$1,234,567.89"},
+		{Dollars(10000000000), "$100,000,000.00"},
+		{Dollars(math.MaxInt64), "$92,233,720,368,547,758.07"},
+		{Dollars(math.MinInt64), "-$92,233,720,368,547,758.08"},
+		{New(123456, "EUR"), "€1,234.56"},
+		{New(-99, "CAD"), "-CA$0.99"},
+		{New(123456, "JPY"), "1,234.56 JPY"},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, a := range []Amount{Dollars(0), Dollars(-12500), Dollars(math.MaxInt64), Dollars(math.MinInt64), New(4200, "GBP")} {
+		data, err := json.Marshal(a)
+		if err != nil {
+			t.Fatalf("Marshal(%+v) = %v", a, err)
+		}
+		var got Amount
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s) = %v", data, err)
+		}
+		if got != a {
+			t.Errorf("round trip of %+v = %+v via %s", a, got, data)
+		}
+	}
+
+	data, _ := json.Marshal(Dollars(-1250))
+	if string(data) != `{"cents":-1250,"currency":"USD","formatted":"-$12.50"}` {
+		t.Errorf("Marshal = %s", data)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Amount
+		wantErr bool
+	}{
+		{`{"cents": 100, "currency": "USD"}`, Dollars(100), false},
+		{`{"cents": 100, "currency": "USD", "formatted": "$5.00"}`, Dollars(100), false},
+		{`{"cents": 100}`, Amount{}, true},
+		{`{"cents": 100, "currency": ""}`, Amount{}, true},
+		{`{"cents": 100, "currency": "usd"}`, Amount{}, true},
+		{`{"cents": 100, "currency": "US"}`, Amount{}, true},
+		{`{"cents": 100, "currency": "USDT"}`, Amount{}, true},
+		{`{"cents": 100, "currency": "U$D"}`, Amount{}, true},
+		{`{"cents": 1.5, "currency": "USD"}`, Amount{}, true},
+		{`"$1.00"`, Amount{}, true},
+	}
+	for _, tt := range tests {
+		var got Amount
+		err := json.Unmarshal([]byte(tt.data), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, want error %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Package openapi serves the embedded API contract and checks live traffic
+// against it
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	refunddemo "refund-demo"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/gofiber/fiber/v2"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// Spec is the parsed contract plus a router that maps requests to operations
+type Spec struct {
+	doc    *openapi3.T
+	json   []byte
+	router routers.Router
+}
+
+// Load parses and validates the embedded openapi.yaml
+func Load() (*Spec, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(refunddemo.OpenAPISpec)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: parse spec: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("openapi: invalid spec: %w", err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: encode spec: %w", err)
+	}
+
+	// Match on paths alone; the servers list is for readers of the document and
+	// would otherwise tie validation to one host and port
+	doc.Servers = nil
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: build router: %w", err)
+	}
+	return &Spec{doc: doc, json: data, router: router}, nil
+}
+
+// Doc returns the parsed document
+func (s *Spec) Doc() *openapi3.T {
+	return s.doc
+}
+
+// JSONHandler serves the spec as JSON
+func (s *Spec) JSONHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(s.json)
+	}
+}
+
+// DocsHandler serves an interactive reference rendered from /openapi.json
+func DocsHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Send(docsPage)
+	}
+}
+
+// Undocumented lists the routes registered on app that have no operation in
+// the spec, as "METHOD /path" with path parameters in OpenAPI form
+func (s *Spec) Undocumented(app *fiber.App) []string {
+	seen := make(map[string]bool)
+	var missing []string
+	// true leaves out middleware mounted with Use
+	for _, r := range app.GetRoutes(true) {
+		// HEAD is registered alongside every GET
+		if r.Method == fiber.MethodHead {
+			continue
+		}
+		path := openAPIPath(r.Path)
+		key := r.Method + " " + path
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		item := s.doc.Paths.Value(path)
+		if item == nil || item.GetOperation(r.Method) == nil {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// openAPIPath rewrites Fiber's :param segments as {param}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			segments[i] = "{" + strings.TrimSuffix(seg[1:], "?") + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Operations lists the operation ID of every documented route
+func (s *Spec) Operations() []string {
+	var ids []string
+	for _, item := range s.doc.Paths.Map() {
+		for _, op := range item.Operations() {
+			ids = append(ids, op.OperationID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CheckSchema validates a JSON document against a named component schema
+func (s *Spec) CheckSchema(name string, data []byte) error {
+	ref := s.doc.Components.Schemas[name]
+	if ref == nil {
+		return fmt.Errorf("openapi: no schema named %s", name)
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return ref.Value.VisitJSON(value)
+}
@@ -0,0 +1,161 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"refund-demo/internal/logging"
+
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// Validation modes, matching the openapi.validation setting
+const (
+	ModeOff    = "off"
+	ModeWarn   = "warn"
+	ModeStrict = "strict"
+)
+
+// errorBody mirrors the API error envelope
+type errorBody struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// Middleware checks each request and response for a documented route against
+// the spec. Mismatches are logged; in strict mode an invalid request is
+// rejected with 400 and an invalid response is replaced with a 500, so drift
+// surfaces as soon as a developer exercises the route. Routes missing from the
+// spec pass through untouched; Undocumented reports those at startup.
+func (s *Spec) Middleware(mode string) fiber.Handler {
+	if mode == ModeOff {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+	opts := &openapi3filter.Options{
+		// Authentication is enforced by the route handlers, not the contract
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+		IncludeResponseStatus: true,
+		MultiError:            true,
+	}
+
+	return func(c *fiber.Ctx) error {
+		req, err := adaptor.ConvertRequest(c, false)
+		if err != nil {
+			return c.Next()
+		}
+		route, params, err := s.router.FindRoute(req)
+		if err != nil {
+			return c.Next()
+		}
+
+		ctx := c.UserContext()
+		logger := logging.Ctx(ctx)
+		input := &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: params,
+			Route:      route,
+			Options:    opts,
+		}
+		if err := openapi3filter.ValidateRequest(ctx, input); err != nil {
+			logger.Warn().Err(err).Str("operation", route.Operation.OperationID).Msg("request does not match the API contract")
+			if mode == ModeStrict {
+				return c.Status(fiber.StatusBadRequest).JSON(errorBody{
+					Error: "request does not match the API contract: " + summary(err),
+					Code:  "bad_request",
+				})
+			}
+		}
+
+		if err := c.Next(); err != nil {
+			// The app error handler writes this response after we return
+			return err
+		}
+
+		if err := validateResponse(ctx, c, input); err != nil {
+			logger.Error().Err(err).
+				Str("operation", route.Operation.OperationID).
+				Int("status", c.Response().StatusCode()).
+				Msg("response does not match the API contract")
+			if mode == ModeStrict {
+				c.Response().Reset()
+				return c.Status(fiber.StatusInternalServerError).JSON(errorBody{
+					Error: "response does not match the API contract: " + summary(err),
+					Code:  "internal_error",
+				})
+			}
+		}
+		return nil
+	}
+}
+
+// validateResponse checks the status, headers and, for JSON, the body of the
+// response c is about to send. Streamed bodies are written after the handler
+// returns, so only their status and headers are checked.
+func validateResponse(ctx context.Context, c *fiber.Ctx, input *openapi3filter.RequestValidationInput) error {
+	resp := c.Response()
+	header := make(http.Header)
+	resp.Header.VisitAll(func(k, v []byte) {
+		header.Add(string(k), string(v))
+	})
+
+	opts := *input.Options
+	contentType := string(resp.Header.ContentType())
+	if resp.IsBodyStream() || !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+		opts.ExcludeResponseBody = true
+	}
+
+	out := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 resp.StatusCode(),
+		Header:                 header,
+		Options:                &opts,
+	}
+	if !opts.ExcludeResponseBody {
+		out.SetBodyBytes(resp.Body())
+	} else {
+		out.Body = io.NopCloser(bytes.NewReader(nil))
+	}
+	return openapi3filter.ValidateResponse(ctx, out)
+}
+
+// summary is the first line of a validation error; the rest is a schema dump
+// that belongs in the logs rather than the response
+func summary(err error) string {
+	msg, _, _ := strings.Cut(err.Error(), "\n")
+	return msg
+}
+
+// CheckExchange validates a response received from a live server against the
+// operation matching req and returns that operation's ID. body is the
+// response body, already read; pass nil for streamed responses to check only
+// the status and headers.
+func (s *Spec) CheckExchange(ctx context.Context, req *http.Request, resp *http.Response, body []byte) (string, error) {
+	route, params, err := s.router.FindRoute(req)
+	if err != nil {
+		return "", err
+	}
+	opts := &openapi3filter.Options{
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+		IncludeResponseStatus: true,
+		MultiError:            true,
+		ExcludeResponseBody:   body == nil,
+	}
+	out := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: params,
+			Route:      route,
+			Options:    opts,
+		},
+		Status:  resp.StatusCode,
+		Header:  resp.Header,
+		Options: opts,
+	}
+	out.SetBodyBytes(body)
+	return route.Operation.OperationID, openapi3filter.ValidateResponse(ctx, out)
+}
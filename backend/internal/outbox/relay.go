@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"refund-demo/internal/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Relay publishes outbox events to a Sink with at-least-once semantics.
+//
+// Each batch is leased in a short transaction and published outside it, so no
+// connection or lock is held while the sink works. Events are read in id order
+// and a return's events are only ever leased by one relay at a time; if an
+// event fails to publish, later events for the same return are held back until
+// it succeeds, so ordering is preserved per return_id while other returns keep
+// flowing.
+type Relay struct {
+	db   *store.DB
+	sink Sink
+
+	PollInterval time.Duration
+	BatchSize    int
+	Retention    time.Duration
+	// Lease is how long a claimed batch is reserved; events not published by
+	// then are released for the next claim
+	Lease time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewRelay creates a relay with default settings
+func NewRelay(db *store.DB, sink Sink) *Relay {
+	return &Relay{
+		db:           db,
+		sink:         sink,
+		PollInterval: time.Second,
+		BatchSize:    100,
+		Retention:    7 * 24 * time.Hour,
+		Lease:        2 * time.Minute,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start launches the polling loop in the background
+func (r *Relay) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.PollInterval)
+		defer ticker.Stop()
+		purge := time.NewTicker(time.Hour)
+		defer purge.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				// Keep draining while batches come back full
+				for {
+					n, err := r.RunOnce()
+					if err != nil {
+						log.Error().Err(err).Msg("outbox relay batch failed")
+						break
+					}
+					if n < r.BatchSize {
+						break
+					}
+				}
+			case <-purge.C:
+				if n, err := store.PurgePublishedEvents(context.Background(), r.db, r.Retention); err != nil {
+					log.Error().Err(err).Msg("failed to purge published outbox events")
+				} else if n > 0 {
+					log.Info().Int64("purged", n).Msg("purged published outbox events")
+				}
+			}
+		}
+	}()
+	log.Info().Dur("poll_interval", r.PollInterval).Msg("outbox relay started")
+}
+
+// Stop halts polling, waits for the in-flight batch and closes the sink
+func (r *Relay) Stop() {
+	close(r.stop)
+	r.wg.Wait()
+	if err := r.sink.Close(); err != nil {
+		log.Error().Err(err).Msg("failed to close outbox sink")
+	}
+	log.Info().Msg("outbox relay stopped")
+}
+
+// RunOnce publishes a single batch and returns how many events were read.
+// It returns 0 without error when another relay is claiming.
+func (r *Relay) RunOnce() (int, error) {
+	events, err := store.ClaimOutboxEvents(context.Background(), r.db, r.BatchSize, r.Lease)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	// Stop publishing before the lease runs out so another relay can't pick
+	// up events this one is still working through
+	ctx, cancel := context.WithTimeout(context.Background(), r.Lease)
+	defer cancel()
+
+	published := make([]int64, 0, len(events))
+	var held []int64
+	blocked := make(map[string]bool)
+	for _, event := range events {
+		if blocked[event.AggregateID] || ctx.Err() != nil {
+			held = append(held, event.ID)
+			continue
+		}
+		if err := r.sink.Publish(ctx, event); err != nil {
+			log.Warn().Err(err).
+				Int64("id", event.ID).
+				Str("return_id", event.AggregateID).
+				Str("event_type", event.EventType).
+				Msg("failed to publish outbox event, holding later events for this return")
+			blocked[event.AggregateID] = true
+			held = append(held, event.ID)
+			continue
+		}
+		published = append(published, event.ID)
+	}
+
+	// An event published but not marked is published again once its lease
+	// expires, which at-least-once delivery allows
+	if err := store.MarkEventsPublished(context.Background(), r.db, published); err != nil {
+		return 0, err
+	}
+	if err := store.ReleaseOutboxEvents(context.Background(), r.db, held); err != nil {
+		return len(published), err
+	}
+
+	if len(held) > 0 {
+		// Don't spin on a failing sink; the next tick retries
+		return len(published), nil
+	}
+	return len(events), nil
+}
@@ -0,0 +1,215 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"refund-demo/internal/config"
+	"refund-demo/internal/store"
+)
+
+// Sink publishes outbox events to a downstream system. Publish must only
+// return nil once the event is durably accepted; the relay retries otherwise,
+// so sinks see each event at least once and consumers should de-duplicate on EventID.
+type Sink interface {
+	Publish(ctx context.Context, event store.OutboxEvent) error
+	Close() error
+}
+
+// WriterSink writes one JSON object per line, e.g. to stdout or a JSONL file
+type WriterSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewStdoutSink writes events as JSON lines to stdout
+func NewStdoutSink() *WriterSink {
+	return &WriterSink{w: os.Stdout}
+}
+
+// NewFileSink appends events as JSON lines to the file at path
+func NewFileSink(path string) (*WriterSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterSink{w: f, closer: f}, nil
+}
+
+func (s *WriterSink) Publish(_ context.Context, event store.OutboxEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if f, ok := s.w.(*os.File); ok {
+		return f.Sync()
+	}
+	return nil
+}
+
+func (s *WriterSink) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// HTTPSink POSTs each event as JSON to a fixed URL; any non-2xx is a failure
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *HTTPSink) Publish(ctx context.Context, event store.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.EventID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sink responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *HTTPSink) Close() error {
+	return nil
+}
+
+// Publisher is the subset of a NATS-style connection the bus sink needs.
+// *nats.Conn satisfies it; MemoryBus is an in-process fake. The bus sink is
+// for embedding with a connection the caller subscribes to, so it has no
+// outbox.sink option of its own.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// BusSink publishes events to subjects of the form <prefix>.<event_type>,
+// e.g. refunds.return.updated
+type BusSink struct {
+	conn   Publisher
+	prefix string
+}
+
+func NewBusSink(conn Publisher, prefix string) *BusSink {
+	return &BusSink{conn: conn, prefix: prefix}
+}
+
+func (s *BusSink) Publish(_ context.Context, event store.OutboxEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	subject := event.EventType
+	if s.prefix != "" {
+		subject = s.prefix + "." + subject
+	}
+	return s.conn.Publish(subject, data)
+}
+
+func (s *BusSink) Close() error {
+	return nil
+}
+
+// Message is a single publish recorded by MemoryBus
+type Message struct {
+	Subject string
+	Data    []byte
+}
+
+// MemoryBus is an in-process Publisher for tests. It records
+// every message and fans out to subscribers whose subject prefix matches.
+type MemoryBus struct {
+	mu       sync.Mutex
+	messages []Message
+	subs     map[string][]chan Message
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subs: make(map[string][]chan Message)}
+}
+
+func (b *MemoryBus) Publish(subject string, data []byte) error {
+	msg := Message{Subject: subject, Data: append([]byte(nil), data...)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, msg)
+	for prefix, chans := range b.subs {
+		if !strings.HasPrefix(subject, prefix) {
+			continue
+		}
+		for _, ch := range chans {
+			select {
+			case ch <- msg:
+			default:
+				// Slow subscriber; the message is still recorded in Messages
+			}
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a buffered channel receiving messages whose subject starts with prefix
+func (b *MemoryBus) Subscribe(prefix string, buffer int) <-chan Message {
+	ch := make(chan Message, buffer)
+	b.mu.Lock()
+	b.subs[prefix] = append(b.subs[prefix], ch)
+	b.mu.Unlock()
+	return ch
+}
+
+// Messages returns a copy of everything published so far
+func (b *MemoryBus) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.messages...)
+}
+
+// NewSink builds the sink selected by cfg.Sink: "stdout", "file" or "http".
+// It returns nil when the sink is "none".
+func NewSink(cfg config.Outbox) (Sink, error) {
+	switch cfg.Sink {
+	case "none":
+		return nil, nil
+	case "stdout":
+		return NewStdoutSink(), nil
+	case "file":
+		return NewFileSink(cfg.File)
+	case "http":
+		return NewHTTPSink(cfg.HTTPURL), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox sink %q", cfg.Sink)
+	}
+}
@@ -0,0 +1,46 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"refund-demo/internal/config"
+	"refund-demo/internal/store"
+)
+
+func TestBusSinkPublishesToSubscribers(t *testing.T) {
+	bus := NewMemoryBus()
+	updates := bus.Subscribe("refunds.return.updated", 1)
+	sink := NewBusSink(bus, "refunds")
+
+	event := store.OutboxEvent{ID: 7, EventID: "evt-7", AggregateID: "01HZDEM0001AAAAAAAAAAAAAAA", EventType: "return.updated", Payload: json.RawMessage(`{}`)}
+	if err := sink.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+
+	select {
+	case msg := <-updates:
+		if msg.Subject != "refunds.return.updated" {
+			t.Fatalf("subject = %q, want refunds.return.updated", msg.Subject)
+		}
+		var got store.OutboxEvent
+		if err := json.Unmarshal(msg.Data, &got); err != nil || got.EventID != "evt-7" {
+			t.Fatalf("message = %s (%v), want event evt-7", msg.Data, err)
+		}
+	default:
+		t.Fatal("subscriber received nothing")
+	}
+}
+
+func TestNewSinkRejectsUnknownSinks(t *testing.T) {
+	for _, name := range []string{"memory", "kafka"} {
+		if _, err := NewSink(config.Outbox{Sink: name}); err == nil {
+			t.Errorf("NewSink(%q) succeeded; events would be marked published with nobody consuming them", name)
+		}
+	}
+	sink, err := NewSink(config.Outbox{Sink: "none"})
+	if err != nil || sink != nil {
+		t.Fatalf("NewSink(none) = %v, %v; want no sink", sink, err)
+	}
+}
@@ -0,0 +1,220 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// Limit is a token bucket: Burst tokens at most, refilled at Rate tokens per Period
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// PerMinute is a limit of n requests per minute with a burst of n
+func PerMinute(n int) Limit {
+	return Limit{Rate: n, Period: time.Minute, Burst: n}
+}
+
+func (l Limit) perSecond() float64 {
+	return float64(l.Rate) / l.Period.Seconds()
+}
+
+// Result describes the bucket after a take
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a token is available; zero when allowed
+	RetryAfter time.Duration
+}
+
+// Store holds bucket state
+type Store interface {
+	// Take removes one token from the bucket for key if available
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Refund returns a token taken for a request that another bucket rejected
+	Refund(ctx context.Context, key string, limit Limit) error
+}
+
+// queryTimeout bounds each Postgres call; the limiter fails open, so a slow
+// database shouldn't hold up requests for long
+const queryTimeout = time.Second
+
+func result(tokens float64, allowed bool, limit Limit) Result {
+	rate := limit.perSecond()
+	r := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     time.Duration((float64(limit.Burst) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return r
+}
+
+// sweepInterval controls how often idle buckets are dropped
+const sweepInterval = 5 * time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryStore keeps buckets in process memory; limits are per replica
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), lastSweep: time.Now(), now: time.Now}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+
+	b := s.refill(key, limit, now)
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return result(b.tokens, allowed, limit), nil
+}
+
+func (s *MemoryStore) Refund(_ context.Context, key string, limit Limit) error {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.refill(key, limit, now)
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+1)
+	return nil
+}
+
+// refill brings the bucket for key up to date, creating it full if new
+func (s *MemoryStore) refill(key string, limit Limit, now time.Time) *bucket {
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.perSecond())
+	b.updated = now
+	return b
+}
+
+// sweep drops buckets idle long enough to have refilled completely
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > sweepInterval {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so limits are
+// shared across replicas. Each take is a single atomic upsert.
+type PostgresStore struct {
+	db *sqlx.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(db *sqlx.DB) *PostgresStore {
+	return &PostgresStore{db: db, lastSweep: time.Now()}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.maybeSweep()
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	// Refill based on elapsed time; a new bucket starts full
+	var tokens float64
+	err := s.db.GetContext(ctx, &tokens, `
+		INSERT INTO rate_limit_buckets AS b (bucket_key, tokens, updated_at)
+		VALUES ($1, $2::float8, clock_timestamp())
+		ON CONFLICT (bucket_key) DO UPDATE SET
+			tokens = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM (clock_timestamp() - b.updated_at)) * $3::float8),
+			updated_at = clock_timestamp()
+		RETURNING tokens`,
+		key, limit.Burst, limit.perSecond(),
+	)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Take a token only if one is available. The row lock makes concurrent
+	// takes re-check the condition, so a bucket never goes negative.
+	err = s.db.GetContext(ctx, &tokens, `
+		UPDATE rate_limit_buckets SET tokens = tokens - 1
+		WHERE bucket_key = $1 AND tokens >= 1
+		RETURNING tokens`,
+		key,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return result(tokens, false, limit), nil
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	return result(tokens, true, limit), nil
+}
+
+func (s *PostgresStore) Refund(ctx context.Context, key string, limit Limit) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE rate_limit_buckets SET tokens = LEAST($2::float8, tokens + 1) WHERE bucket_key = $1",
+		key, limit.Burst,
+	)
+	return err
+}
+
+func (s *PostgresStore) maybeSweep() {
+	s.mu.Lock()
+	due := time.Since(s.lastSweep) > sweepInterval
+	if due {
+		s.lastSweep = time.Now()
+	}
+	s.mu.Unlock()
+
+	if !due {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+		defer cancel()
+		_, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < now() - interval '1 hour'")
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to sweep rate limit buckets")
+		}
+	}()
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestStore(now *time.Time) *MemoryStore {
+	s := NewMemoryStore()
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	s := newTestStore(&now)
+	limit := PerMinute(3)
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		res, _ := s.Take(ctx, "k", limit)
+		if !res.Allowed || res.Remaining != i {
+			t.Fatalf("take %d = allowed %v remaining %d, want allowed with %d left", 3-i, res.Allowed, res.Remaining, i)
+		}
+	}
+
+	res, _ := s.Take(ctx, "k", limit)
+	if res.Allowed {
+		t.Fatal("take from an empty bucket was allowed")
+	}
+	if res.RetryAfter != 20*time.Second || res.Reset != time.Minute {
+		t.Fatalf("retry after %v, reset %v; want 20s and 1m", res.RetryAfter, res.Reset)
+	}
+
+	now = now.Add(20 * time.Second)
+	if res, _ := s.Take(ctx, "k", limit); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("after 20s: allowed %v remaining %d, want one token refilled", res.Allowed, res.Remaining)
+	}
+
+	now = now.Add(time.Hour)
+	if res, _ := s.Take(ctx, "k", limit); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("after an hour: remaining %d, want refill capped at the burst", res.Remaining)
+	}
+}
+
+func TestMemoryStoreBucketsAreIndependent(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+	limit := PerMinute(1)
+	ctx := context.Background()
+
+	s.Take(ctx, "a", limit)
+	if res, _ := s.Take(ctx, "b", limit); !res.Allowed {
+		t.Fatal("draining bucket a rejected bucket b")
+	}
+}
+
+func TestMemoryStoreRefund(t *testing.T) {
+	now := time.Now()
+	s := newTestStore(&now)
+	limit := PerMinute(2)
+	ctx := context.Background()
+
+	s.Take(ctx, "k", limit)
+	s.Take(ctx, "k", limit)
+	if err := s.Refund(ctx, "k", limit); err != nil {
+		t.Fatalf("Refund() = %v", err)
+	}
+	if res, _ := s.Take(ctx, "k", limit); !res.Allowed {
+		t.Fatal("refunded token could not be taken")
+	}
+
+	s.Refund(ctx, "k", limit)
+	s.Refund(ctx, "k", limit)
+	s.Refund(ctx, "k", limit)
+	if res, _ := s.Take(ctx, "k", limit); res.Remaining != 1 {
+		t.Fatalf("remaining %d after refunds, want refunds capped at the burst", res.Remaining)
+	}
+}
@@ -1,34 +1,178 @@
 package scraper
 
 import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"refund-demo/internal/config"
+	"refund-demo/internal/metrics"
 	"refund-demo/internal/store"
+	"refund-demo/internal/tracing"
 
-	"github.com/jmoiron/sqlx"
 	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/codes"
 )
 
-func StartBackgroundJob(db *sqlx.DB) {
-	c := cron.New()
-	
-	// Run every day at midnight
-	_, err := c.AddFunc("0 0 * * *", func() {
+// Scheduler runs the scraper, calibration, calibration reload and stall
+// detection jobs on cron schedules
+type Scheduler struct {
+	cron    *cron.Cron
+	jobs    map[string]cron.EntryID
+	running atomic.Bool
+}
+
+// NewScheduler registers the scraper, calibration and stall jobs on the
+// schedules in cfg without starting them. ETAs of the inserted returns come
+// from eta, which also applies each calibration the job fits.
+func NewScheduler(db *store.DB, cfg *config.Config, eta *store.ETAEngine) (*Scheduler, error) {
+	s := &Scheduler{cron: cron.New(), jobs: map[string]cron.EntryID{}}
+
+	// Run on the configured schedule (default: every day at midnight)
+	err := s.add("scraper", cfg.Scheduler.ScraperSpec, func() {
 		log.Info().Msg("Running scheduled scraper job")
-		
+		start := time.Now()
+		ctx, span := tracing.Tracer().Start(context.Background(), "job.scraper")
+		defer span.End()
+
 		// Insert demo data
-		returnID, err := store.InsertDemoReturn(db)
+		returnID, err := store.InsertDemoReturn(ctx, db, eta)
+		metrics.JobRun("scraper", time.Since(start), err)
 		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "insert failed")
 			log.Error().Err(err).Msg("Failed to insert demo return")
 		} else {
 			log.Info().Str("return_id", returnID).Msg("Inserted demo return")
 		}
 	})
-	
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to schedule scraper job")
-		return
+		return nil, err
+	}
+
+	// Refit confidence once the day's returns have settled (default: 02:30)
+	err = s.add("calibration", cfg.Scheduler.CalibrationSpec, func() {
+		start := time.Now()
+		ctx, span := tracing.Tracer().Start(context.Background(), "job.calibration")
+		defer span.End()
+
+		calibration, err := store.CalibrateETAs(ctx, db, eta, cfg.Calibration.MinPredictions)
+		if errors.Is(err, store.ErrCalibrationLocked) {
+			log.Debug().Msg("Calibration already running on another replica")
+			return
+		}
+		metrics.JobRun("calibration", time.Since(start), err)
+		switch {
+		case err != nil:
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "calibration failed")
+			log.Error().Err(err).Msg("Failed to calibrate ETA confidence")
+		case calibration == nil:
+			log.Info().Int("min_predictions", cfg.Calibration.MinPredictions).Msg("Too few completed estimates to calibrate ETA confidence")
+		default:
+			log.Info().Int("predictions", calibration.Predictions).Int("points", len(calibration.Points)).Msg("Calibrated ETA confidence")
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply calibrations fitted on other replicas (default: every ten minutes)
+	err = s.add("calibration_reload", cfg.Scheduler.CalibrationReloadSpec, func() {
+		start := time.Now()
+		ctx, span := tracing.Tracer().Start(context.Background(), "job.calibration_reload")
+		defer span.End()
+
+		err := store.LoadCalibration(ctx, db, eta)
+		metrics.JobRun("calibration_reload", time.Since(start), err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "reload failed")
+			log.Error().Err(err).Msg("Failed to reload ETA confidence calibration")
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Flag returns that have sat in ACCEPTED or REVIEW too long (default: hourly)
+	policy := store.StallPolicy{
+		Factor:     cfg.Stall.ThresholdFactor,
+		Percentile: cfg.Stall.Percentile,
+		MinSamples: cfg.Stall.MinSamples,
+	}
+	err = s.add("stall_detector", cfg.Scheduler.StallSpec, func() {
+		start := time.Now()
+		ctx, span := tracing.Tracer().Start(context.Background(), "job.stall_detector")
+		defer span.End()
+
+		stalled, err := store.DetectStalledReturns(ctx, db, policy, start)
+		metrics.JobRun("stall_detector", time.Since(start), err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "stall detection failed")
+			log.Error().Err(err).Int("flagged", len(stalled)).Msg("Failed to detect stalled returns")
+			return
+		}
+		if len(stalled) > 0 {
+			log.Info().Int("flagged", len(stalled)).Msg("Flagged stalled returns")
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// add schedules job under name, which NextRuns reports it by
+func (s *Scheduler) add(name, spec string, job func()) error {
+	id, err := s.cron.AddFunc(spec, job)
+	if err != nil {
+		return err
+	}
+	s.jobs[name] = id
+	return nil
+}
+
+// Start begins running the job in the background
+func (s *Scheduler) Start() {
+	s.cron.Start()
+	s.running.Store(true)
+	log.Info().Msg("Background jobs started")
+}
+
+// Stop prevents new runs and waits for a run in progress to finish
+func (s *Scheduler) Stop() {
+	s.running.Store(false)
+	<-s.cron.Stop().Done()
+	log.Info().Msg("Background jobs stopped")
+}
+
+// Running reports whether the schedule is active
+func (s *Scheduler) Running() bool {
+	return s.running.Load()
+}
+
+// NextRun is when the earliest job will next fire, or zero if none is scheduled
+func (s *Scheduler) NextRun() time.Time {
+	var next time.Time
+	for _, run := range s.NextRuns() {
+		if !run.IsZero() && (next.IsZero() || run.Before(next)) {
+			next = run
+		}
+	}
+	return next
+}
+
+// NextRuns is when each job will next fire, by name. Times are zero until
+// the scheduler starts.
+func (s *Scheduler) NextRuns() map[string]time.Time {
+	runs := make(map[string]time.Time, len(s.jobs))
+	for name, id := range s.jobs {
+		runs[name] = s.cron.Entry(id).Next
 	}
-	
-	c.Start()
-	log.Info().Msg("Background scraper job started")
+	return runs
 }
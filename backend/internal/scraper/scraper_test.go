@@ -0,0 +1,42 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"refund-demo/internal/config"
+)
+
+func TestSchedulerNextRuns(t *testing.T) {
+	cfg := config.Default()
+	cfg.Scheduler.ScraperSpec = "0 0 1 1 *"
+	cfg.Scheduler.CalibrationSpec = "@every 1h"
+	cfg.Scheduler.StallSpec = "@every 1m"
+
+	s, err := NewScheduler(nil, cfg, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler() = %v", err)
+	}
+	if !s.NextRun().IsZero() {
+		t.Fatalf("NextRun() = %v before Start, want zero", s.NextRun())
+	}
+
+	s.Start()
+	defer s.Stop()
+
+	runs := s.NextRuns()
+	for _, name := range []string{"scraper", "calibration", "calibration_reload", "stall_detector"} {
+		if runs[name].IsZero() {
+			t.Errorf("NextRuns()[%q] is zero, want a scheduled time", name)
+		}
+	}
+	if !runs["stall_detector"].Before(runs["calibration"]) || !runs["calibration"].Before(runs["scraper"]) {
+		t.Fatalf("NextRuns() = %v, want stall detector before calibration before scraper", runs)
+	}
+	if got := s.NextRun(); !got.Equal(runs["stall_detector"]) {
+		t.Fatalf("NextRun() = %v, want the earliest job at %v", got, runs["stall_detector"])
+	}
+	if got := time.Until(s.NextRun()); got > time.Minute {
+		t.Fatalf("NextRun() is %v away, want within the minute", got)
+	}
+}
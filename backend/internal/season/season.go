@@ -0,0 +1,248 @@
+// Package season describes IRS filing seasons: when e-filing opens, when
+// PATH Act holds lift, and the peak weeks and holidays that slow processing.
+package season
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Season is the filing season for one tax year's returns, which runs in the
+// following calendar year. Dates are whole days in UTC.
+type Season struct {
+	TaxYear int `yaml:"tax_year"`
+	// Opens is when the IRS starts accepting e-filed returns; returns filed
+	// earlier wait until then
+	Opens time.Time `yaml:"opens"`
+	// PATHRelease is the first day refunds claiming EITC or ACTC may be
+	// issued; the PATH Act holds them until mid-February
+	PATHRelease time.Time `yaml:"path_release"`
+	// Peak lists the busiest stretches, when processing runs slower
+	Peak []Period `yaml:"peak"`
+	// Holidays are days the IRS does not process returns
+	Holidays []time.Time `yaml:"holidays"`
+}
+
+// Period is a run of days, inclusive of both ends, that adds ExtraDays to any
+// estimate it overlaps
+type Period struct {
+	Start     time.Time `yaml:"start"`
+	End       time.Time `yaml:"end"`
+	ExtraDays int       `yaml:"extra_days"`
+}
+
+// Calendar holds seasons by tax year
+type Calendar struct {
+	seasons map[int]*Season
+}
+
+// builtin are the seasons known when this was written. The 2027 season is
+// projected; override it in a calendar file once the IRS announces it.
+var builtin = []Season{
+	{
+		TaxYear:     2024,
+		Opens:       date(2025, time.January, 27),
+		PATHRelease: date(2025, time.February, 15),
+		Peak: []Period{
+			{Start: date(2025, time.February, 3), End: date(2025, time.February, 28), ExtraDays: 3},
+			{Start: date(2025, time.April, 7), End: date(2025, time.April, 18), ExtraDays: 2},
+		},
+		Holidays: []time.Time{date(2025, time.January, 20), date(2025, time.February, 17)},
+	},
+	{
+		TaxYear:     2025,
+		Opens:       date(2026, time.January, 26),
+		PATHRelease: date(2026, time.February, 15),
+		Peak: []Period{
+			{Start: date(2026, time.February, 2), End: date(2026, time.February, 27), ExtraDays: 3},
+			{Start: date(2026, time.April, 6), End: date(2026, time.April, 17), ExtraDays: 2},
+		},
+		Holidays: []time.Time{date(2026, time.January, 19), date(2026, time.February, 16)},
+	},
+	{
+		TaxYear:     2026,
+		Opens:       date(2027, time.January, 25),
+		PATHRelease: date(2027, time.February, 15),
+		Peak: []Period{
+			{Start: date(2027, time.February, 1), End: date(2027, time.February, 26), ExtraDays: 3},
+			{Start: date(2027, time.April, 5), End: date(2027, time.April, 16), ExtraDays: 2},
+		},
+		Holidays: []time.Time{date(2027, time.January, 18), date(2027, time.February, 15)},
+	},
+}
+
+// Default returns a calendar of the built-in seasons
+func Default() *Calendar {
+	c := &Calendar{seasons: make(map[int]*Season, len(builtin))}
+	for i := range builtin {
+		s := builtin[i]
+		c.seasons[s.TaxYear] = &s
+	}
+	return c
+}
+
+// calendarFile is the layout of a calendar file
+type calendarFile struct {
+	Seasons []Season `yaml:"seasons"`
+}
+
+// Load returns the built-in seasons with those in the YAML file at path added
+// or, for the same tax year, replacing them. An empty path loads only the
+// built-in seasons.
+func Load(path string) (*Calendar, error) {
+	c := Default()
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("season: read %s: %w", path, err)
+	}
+	var file calendarFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("season: parse %s: %w", path, err)
+	}
+
+	var problems []string
+	for i := range file.Seasons {
+		s := file.Seasons[i]
+		if err := s.Validate(); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		s.normalize()
+		c.seasons[s.TaxYear] = &s
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("season: %s:\n  - %s", path, strings.Join(problems, "\n  - "))
+	}
+	return c, nil
+}
+
+// For returns the season in which taxYear's returns are filed
+func (c *Calendar) For(taxYear int) (*Season, bool) {
+	s, ok := c.seasons[taxYear]
+	return s, ok
+}
+
+// TaxYears lists the tax years the calendar has seasons for, oldest first
+func (c *Calendar) TaxYears() []int {
+	years := make([]int, 0, len(c.seasons))
+	for year := range c.seasons {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+	return years
+}
+
+// TaxYearFiledIn is the tax year of a return filed at t. Returns are filed in
+// the year after the tax year, and extensions run to October, so this holds
+// for the whole calendar year.
+func TaxYearFiledIn(t time.Time) int {
+	return t.Year() - 1
+}
+
+// Validate checks that the season's dates fall in the year after its tax year
+// and are in order
+func (s *Season) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	filingYear := s.TaxYear + 1
+	check(s.TaxYear >= 2000 && s.TaxYear <= 2100, "tax_year: %d is out of range", s.TaxYear)
+	check(!s.Opens.IsZero() && s.Opens.Year() == filingYear, "opens: must be a date in %d", filingYear)
+	check(!s.PATHRelease.IsZero() && s.PATHRelease.Year() == filingYear, "path_release: must be a date in %d", filingYear)
+	check(!s.PATHRelease.Before(s.Opens), "path_release: must not be before opens")
+	for i, p := range s.Peak {
+		check(!p.Start.IsZero() && !p.End.IsZero(), "peak[%d]: start and end are required", i)
+		check(!p.End.Before(p.Start), "peak[%d]: end is before start", i)
+		check(p.ExtraDays >= 0, "peak[%d].extra_days: must not be negative", i)
+	}
+	for i, h := range s.Holidays {
+		check(h.Year() == filingYear, "holidays[%d]: must be a date in %d", i, filingYear)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("tax year %d: %s", s.TaxYear, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// normalize truncates every date to a UTC day so comparisons ignore the time
+// and zone a file may have given
+func (s *Season) normalize() {
+	s.Opens = day(s.Opens)
+	s.PATHRelease = day(s.PATHRelease)
+	s.Peak = append([]Period(nil), s.Peak...)
+	for i := range s.Peak {
+		s.Peak[i].Start = day(s.Peak[i].Start)
+		s.Peak[i].End = day(s.Peak[i].End)
+	}
+	s.Holidays = append([]time.Time(nil), s.Holidays...)
+	for i := range s.Holidays {
+		s.Holidays[i] = day(s.Holidays[i])
+	}
+}
+
+// ProcessingStart is when the IRS starts working a return filed at filed:
+// the season's opening for early filers, otherwise when it was filed
+func (s *Season) ProcessingStart(filed time.Time) time.Time {
+	if day(filed).Before(s.Opens) {
+		return s.Opens
+	}
+	return filed
+}
+
+// PATHHeld reports whether a refund claiming EITC or ACTC is still held at t
+func (s *Season) PATHHeld(t time.Time) bool {
+	return day(t).Before(s.PATHRelease)
+}
+
+// PeakAt returns the peak period t falls in, if any
+func (s *Season) PeakAt(t time.Time) (Period, bool) {
+	d := day(t)
+	for _, p := range s.Peak {
+		if !d.Before(p.Start) && !d.After(p.End) {
+			return p, true
+		}
+	}
+	return Period{}, false
+}
+
+// Delay is the extra days processing between from and to takes: the extra
+// days of each peak period the span overlaps, plus one per holiday in it
+func (s *Season) Delay(from, to time.Time) int {
+	from, to = day(from), day(to)
+	days := 0
+	for _, p := range s.Peak {
+		if !p.End.Before(from) && !p.Start.After(to) {
+			days += p.ExtraDays
+		}
+	}
+	for _, h := range s.Holidays {
+		if !h.Before(from) && !h.After(to) {
+			days++
+		}
+	}
+	return days
+}
+
+func date(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+// day truncates t to the start of its day, keeping the calendar date t has in
+// its own zone
+func day(t time.Time) time.Time {
+	return date(t.Year(), t.Month(), t.Day())
+}
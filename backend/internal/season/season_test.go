@@ -0,0 +1,114 @@
+package season
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	s, ok := Default().For(2025)
+	if !ok {
+		t.Fatal("no built-in season for tax year 2025")
+	}
+	// 2025 season: peaks Feb 2-27 (+3) and Apr 6-17 (+2); holidays Jan 19 and Feb 16
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{"before the season", date(2026, time.January, 5), date(2026, time.January, 10), 0},
+		{"over a holiday", date(2026, time.January, 10), date(2026, time.January, 25), 1},
+		{"into a peak", date(2026, time.January, 26), date(2026, time.February, 5), 3},
+		{"peak and holiday", date(2026, time.February, 1), date(2026, time.February, 20), 4},
+		{"starts on a peak's last day", date(2026, time.February, 27), date(2026, time.March, 10), 3},
+		{"ends on a peak's first day", date(2026, time.March, 20), date(2026, time.April, 6), 2},
+		{"between peaks", date(2026, time.February, 28), date(2026, time.April, 5), 0},
+		{"whole season", date(2026, time.January, 1), date(2026, time.April, 30), 7},
+		{"single holiday in a peak", date(2026, time.February, 16), date(2026, time.February, 16), 4},
+		{"local date is kept", time.Date(2026, time.April, 17, 23, 30, 0, 0, ny), time.Date(2026, time.April, 20, 9, 0, 0, 0, ny), 2},
+		{"to before from", date(2026, time.March, 1), date(2026, time.February, 1), 0},
+	}
+	for _, tt := range tests {
+		if got := s.Delay(tt.from, tt.to); got != tt.want {
+			t.Errorf("%s: Delay(%s, %s) = %d, want %d", tt.name, tt.from.Format(time.DateOnly), tt.to.Format(time.DateOnly), got, tt.want)
+		}
+	}
+}
+
+func TestSeasonDates(t *testing.T) {
+	s, _ := Default().For(2025)
+
+	if got := s.ProcessingStart(date(2026, time.January, 10)); !got.Equal(s.Opens) {
+		t.Errorf("ProcessingStart(early filer) = %s, want the opening day", got.Format(time.DateOnly))
+	}
+	filed := time.Date(2026, time.March, 3, 14, 0, 0, 0, time.UTC)
+	if got := s.ProcessingStart(filed); !got.Equal(filed) {
+		t.Errorf("ProcessingStart(in season) = %s, want the filing time", got)
+	}
+
+	if !s.PATHHeld(date(2026, time.February, 14)) || s.PATHHeld(date(2026, time.February, 15)) {
+		t.Error("PATH hold should cover days before Feb 15 only")
+	}
+
+	if p, ok := s.PeakAt(date(2026, time.April, 17)); !ok || p.ExtraDays != 2 {
+		t.Errorf("PeakAt(Apr 17) = %+v, %v; want the April peak", p, ok)
+	}
+	if _, ok := s.PeakAt(date(2026, time.April, 18)); ok {
+		t.Error("PeakAt(Apr 18) is in a peak")
+	}
+}
+
+func TestTaxYearFiledIn(t *testing.T) {
+	if got := TaxYearFiledIn(date(2026, time.October, 15)); got != 2025 {
+		t.Errorf("TaxYearFiledIn(2026-10-15) = %d, want 2025", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seasons.yaml")
+	os.WriteFile(path, []byte(`
+seasons:
+  - tax_year: 2025
+    opens: 2026-01-20T15:00:00Z
+    path_release: 2026-02-17
+    holidays: [2026-02-16]
+  - tax_year: 2027
+    opens: 2028-01-24
+    path_release: 2028-02-15
+`), 0o600)
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	s, _ := c.For(2025)
+	if !s.Opens.Equal(date(2026, time.January, 20)) || len(s.Peak) != 0 {
+		t.Errorf("2025 season = %+v, want the file's dates truncated to days and no peaks", s)
+	}
+	if _, ok := c.For(2027); !ok {
+		t.Error("2027 season from the file is missing")
+	}
+	if _, ok := c.For(2024); !ok {
+		t.Error("built-in 2024 season was dropped")
+	}
+}
+
+func TestLoadRejectsInvalidSeasons(t *testing.T) {
+	tests := map[string]string{
+		"wrong year":          "seasons: [{tax_year: 2025, opens: 2025-01-20, path_release: 2026-02-15}]",
+		"release before open": "seasons: [{tax_year: 2025, opens: 2026-02-20, path_release: 2026-02-15}]",
+		"peak ends first":     "seasons: [{tax_year: 2025, opens: 2026-01-20, path_release: 2026-02-15, peak: [{start: 2026-03-10, end: 2026-03-01}]}]",
+		"negative extra days": "seasons: [{tax_year: 2025, opens: 2026-01-20, path_release: 2026-02-15, peak: [{start: 2026-03-01, end: 2026-03-10, extra_days: -1}]}]",
+		"holiday out of year": "seasons: [{tax_year: 2025, opens: 2026-01-20, path_release: 2026-02-15, holidays: [2027-01-01]}]",
+	}
+	for name, doc := range tests {
+		path := filepath.Join(t.TempDir(), "seasons.yaml")
+		os.WriteFile(path, []byte(doc), 0o600)
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: Load() succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"refund-demo/internal/money"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog"
+)
+
+// Adjustment kinds. Offsets reduce the refund (e.g. past-due debts), interest
+// increases it, corrections go either way, and partial payments record money
+// already paid out of the adjusted refund.
+const (
+	AdjustmentOffset         = "offset"
+	AdjustmentInterest       = "interest"
+	AdjustmentCorrection     = "correction"
+	AdjustmentPartialPayment = "partial_payment"
+)
+
+// Reason codes. The offset reasons name the kind of debt the Treasury Offset
+// Program collected; math_error marks an IRS correction of the return itself.
+const (
+	ReasonFederalDebt      = "federal_debt"
+	ReasonStateIncomeTax   = "state_income_tax"
+	ReasonChildSupport     = "child_support"
+	ReasonUnemployment     = "unemployment_compensation"
+	ReasonMathError        = "math_error"
+	ReasonCreditDisallowed = "credit_disallowed"
+)
+
+// offsetReasons describe the debt an offset paid, for explanations
+var offsetReasons = map[string]string{
+	ReasonFederalDebt:    "a past-due federal debt",
+	ReasonStateIncomeTax: "past-due state income tax",
+	ReasonChildSupport:   "past-due child support",
+	ReasonUnemployment:   "an unemployment compensation overpayment",
+}
+
+// correctionReasons describe why the IRS changed the return
+var correctionReasons = map[string]string{
+	ReasonMathError:        "a math or clerical error on the return",
+	ReasonCreditDisallowed: "a credit that could not be allowed as claimed",
+}
+
+// ErrInvalidAdjustment is returned when an adjustment fails validation
+var ErrInvalidAdjustment = errors.New("invalid adjustment")
+
+// Adjustment is one line item changing what the taxpayer receives
+type Adjustment struct {
+	AdjustmentID string    `db:"adjustment_id" json:"adjustment_id"`
+	ReturnID     string    `db:"return_id" json:"-"`
+	Kind         string    `db:"kind" json:"kind"`
+	AmountCents  int64     `db:"amount_cents" json:"-"`
+	Currency     string    `db:"currency" json:"-"`
+	ReasonCode   string    `db:"reason_code" json:"reason_code,omitempty"`
+	Agency       string    `db:"agency" json:"agency,omitempty"`
+	Description  string    `db:"description" json:"description"`
+	OccurredAt   time.Time `db:"occurred_at" json:"occurred_at"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+// Amount is the signed change to the refund
+func (a *Adjustment) Amount() money.Amount {
+	return money.New(a.AmountCents, a.Currency)
+}
+
+// MarshalJSON writes the amount as a money object
+func (a Adjustment) MarshalJSON() ([]byte, error) {
+	type plain Adjustment
+	return json.Marshal(struct {
+		plain
+		Amount money.Amount `json:"amount"`
+	}{plain(a), a.Amount()})
+}
+
+// IsReduction reports whether the adjustment lowers the refund
+func (a *Adjustment) IsReduction() bool {
+	return a.Kind != AdjustmentPartialPayment && a.AmountCents < 0
+}
+
+// Reason describes the reason code in words, or "" if it has none
+func (a *Adjustment) Reason() string {
+	if r, ok := offsetReasons[a.ReasonCode]; ok {
+		return r
+	}
+	return correctionReasons[a.ReasonCode]
+}
+
+// Validate checks the kind, the sign of the amount for that kind, the reason
+// code and agency, and the currency
+func (a *Adjustment) Validate() error {
+	var problem string
+	_, offsetReason := offsetReasons[a.ReasonCode]
+	_, correctionReason := correctionReasons[a.ReasonCode]
+	switch {
+	case !money.ValidCurrency(a.Currency):
+		problem = fmt.Sprintf("currency %q is not an ISO 4217 code", a.Currency)
+	case a.Kind == AdjustmentOffset && a.AmountCents >= 0:
+		problem = "offsets must be negative"
+	case a.Kind == AdjustmentOffset && a.Agency == "":
+		problem = "offsets must name the agency that received them"
+	case a.Kind == AdjustmentOffset && a.ReasonCode != "" && !offsetReason:
+		problem = fmt.Sprintf("reason_code %q is not an offset reason", a.ReasonCode)
+	case (a.Kind == AdjustmentInterest || a.Kind == AdjustmentPartialPayment) && a.AmountCents <= 0:
+		problem = a.Kind + " must be positive"
+	case a.Kind == AdjustmentCorrection && a.AmountCents == 0:
+		problem = "corrections must not be zero"
+	case a.Kind == AdjustmentCorrection && a.ReasonCode != "" && !correctionReason:
+		problem = fmt.Sprintf("reason_code %q is not a correction reason", a.ReasonCode)
+	case (a.Kind == AdjustmentInterest || a.Kind == AdjustmentPartialPayment) && a.ReasonCode != "":
+		problem = a.Kind + " does not take a reason_code"
+	case a.Kind != AdjustmentOffset && a.Kind != AdjustmentInterest && a.Kind != AdjustmentCorrection && a.Kind != AdjustmentPartialPayment:
+		problem = fmt.Sprintf("kind %q is not a known adjustment", a.Kind)
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidAdjustment, problem)
+}
+
+// RefundSummary compares the refund as filed with what the adjustments make of it
+type RefundSummary struct {
+	// Original is the refund claimed on the return
+	Original money.Amount `json:"original"`
+	// Adjusted applies offsets, interest and corrections to Original
+	Adjusted money.Amount `json:"adjusted"`
+	// Paid totals the partial payments already made
+	Paid money.Amount `json:"paid"`
+	// Remaining is Adjusted less Paid
+	Remaining money.Amount `json:"remaining"`
+}
+
+// SummarizeRefund applies adjustments to original. Every amount must share
+// original's currency.
+func SummarizeRefund(original money.Amount, adjustments []Adjustment) (*RefundSummary, error) {
+	adjusted := original
+	paid := money.New(0, original.Currency)
+	var err error
+	for i := range adjustments {
+		a := &adjustments[i]
+		if a.Kind == AdjustmentPartialPayment {
+			paid, err = paid.Add(a.Amount())
+		} else {
+			adjusted, err = adjusted.Add(a.Amount())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("adjustment %s: %w", a.AdjustmentID, err)
+		}
+	}
+	remaining, err := adjusted.Sub(paid)
+	if err != nil {
+		return nil, err
+	}
+	return &RefundSummary{Original: original, Adjusted: adjusted, Paid: paid, Remaining: remaining}, nil
+}
+
+// ListAdjustments returns a return's adjustments in the order they happened
+func ListAdjustments(ctx context.Context, db *DB, returnID string) ([]Adjustment, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	adjustments := []Adjustment{}
+	err := db.SelectContext(ctx, &adjustments,
+		"SELECT * FROM adjustments WHERE return_id=$1 ORDER BY occurred_at, adjustment_id", returnID)
+	return adjustments, err
+}
+
+// insertAdjustment validates and stores a, filling in its ID and timestamps
+func insertAdjustment(ctx context.Context, q sqlx.QueryerContext, a *Adjustment) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	if a.OccurredAt.IsZero() {
+		a.OccurredAt = time.Now().UTC()
+	}
+	return sqlx.GetContext(ctx, q, a, `
+		INSERT INTO adjustments (adjustment_id, return_id, kind, amount_cents, currency, reason_code, agency, description, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING *`,
+		NewULID(), a.ReturnID, a.Kind, a.AmountCents, a.Currency, a.ReasonCode, a.Agency, a.Description, a.OccurredAt,
+	)
+}
+
+// RecordAdjustment adds a to its return and touches the return in the same
+// transaction, so the version (and ETag) changes and stream subscribers are
+// notified. The adjustment defaults to the return's currency and must match it.
+//
+// Like TransitionReturn, the return is read without a lock and only updated at
+// the version read. If expectedVersion is non-zero the adjustment only applies
+// to that version of the return and fails with ErrConflict otherwise (If-Match
+// semantics); with zero, a concurrent write causes a reload and retry. A
+// missing return yields sql.ErrNoRows.
+func RecordAdjustment(ctx context.Context, db *DB, a *Adjustment, expectedVersion int) (*RefundReturn, error) {
+	return retryOnConflict(ctx, db, expectedVersion, func(ctx context.Context) (*RefundReturn, error) {
+		return recordAdjustmentOnce(ctx, db, a, expectedVersion)
+	})
+}
+
+func recordAdjustmentOnce(ctx context.Context, db *DB, a *Adjustment, expectedVersion int) (*RefundReturn, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	r := RefundReturn{}
+	if err := tx.GetContext(ctx, &r, "SELECT * FROM returns WHERE return_id=$1", a.ReturnID); err != nil {
+		return nil, err
+	}
+	if expectedVersion != 0 && r.Version != expectedVersion {
+		return nil, ErrConflict
+	}
+	if a.Currency == "" {
+		a.Currency = r.Currency
+	}
+	if a.Currency != r.Currency {
+		return nil, fmt.Errorf("%w: currency %s does not match the return's %s", ErrInvalidAdjustment, a.Currency, r.Currency)
+	}
+
+	if err := insertAdjustment(ctx, tx, a); err != nil {
+		return nil, err
+	}
+	err = tx.GetContext(ctx, &r,
+		"UPDATE returns SET updated_at=now() WHERE return_id=$1 AND version=$2 RETURNING *", a.ReturnID, r.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, tx.Commit()
+}
+
+// GetReturnDetails loads a return with its adjustments, refund summary and
+// its jurisdiction's stages
+func GetReturnDetails(ctx context.Context, db *DB, id string) (*RefundReturn, error) {
+	r, err := GetReturnByID(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadReturnDetails(ctx, db, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// loadReturnDetails fills in the fields GetReturnDetails adds to a loaded return
+func loadReturnDetails(ctx context.Context, db *DB, r *RefundReturn) error {
+	adjustments, err := ListAdjustments(ctx, db, r.ReturnID)
+	if err != nil {
+		return err
+	}
+	r.Adjustments = adjustments
+
+	revisions, err := ListETARevisions(ctx, db, r.ReturnID)
+	if err != nil {
+		return err
+	}
+	r.EtaChange = lastETAChange(revisions)
+
+	if j, ok := LookupJurisdiction(r.Jurisdiction); ok {
+		r.Stages = j.Stages
+	}
+
+	if r.RefundAmountCents != nil {
+		summary, err := SummarizeRefund(money.New(*r.RefundAmountCents, r.Currency), adjustments)
+		if err != nil {
+			// Still serve the return; the line items show what couldn't be totalled
+			zerolog.Ctx(ctx).Error().Err(err).Msg("failed to summarize refund adjustments")
+		} else {
+			r.Refund = summary
+		}
+	}
+	return nil
+}
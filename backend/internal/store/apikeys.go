@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// apiKeyPrefix marks admin API keys so they are easy to spot in logs and secret scanners
+const apiKeyPrefix = "rk_"
+
+// lastUsedResolution is how stale last_used_at may get before a request
+// refreshes it, so busy keys don't write on every request
+const lastUsedResolution = time.Minute
+
+// Admin API key scopes
+const (
+	ScopeAdmin         = "admin"
+	ScopeReturnsRead   = "returns:read"
+	ScopeReturnsWrite  = "returns:write"
+	ScopeWebhooksRead  = "webhooks:read"
+	ScopeWebhooksWrite = "webhooks:write"
+)
+
+// APIKey is a stored admin credential; the plaintext key is never persisted
+type APIKey struct {
+	KeyID      string         `db:"key_id" json:"key_id"`
+	Name       string         `db:"name" json:"name"`
+	KeyHash    string         `db:"key_hash" json:"-"`
+	Scopes     pq.StringArray `db:"scopes" json:"scopes"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+	LastUsedAt *time.Time     `db:"last_used_at" json:"last_used_at"`
+	RevokedAt  *time.Time     `db:"revoked_at" json:"revoked_at"`
+}
+
+// HasScope reports whether the key grants scope; the admin scope grants everything
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditRecord is one admin request in the audit log
+type AuditRecord struct {
+	AuditID    string    `db:"audit_id" json:"audit_id"`
+	KeyID      *string   `db:"key_id" json:"key_id"`
+	Action     string    `db:"action" json:"action"`
+	Method     string    `db:"method" json:"method"`
+	Path       string    `db:"path" json:"path"`
+	StatusCode int       `db:"status_code" json:"status_code"`
+	RemoteIP   *string   `db:"remote_ip" json:"remote_ip"`
+	OccurredAt time.Time `db:"occurred_at" json:"occurred_at"`
+}
+
+// HashAPIKey returns the stored form of a key. Keys carry 256 bits of entropy,
+// so a fast unsalted hash is sufficient.
+func HashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey generates a new key with the given scopes and returns its
+// plaintext, which cannot be recovered later
+func CreateAPIKey(ctx context.Context, db *DB, name string, scopes []string) (string, *APIKey, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	raw := apiKeyPrefix + hex.EncodeToString(buf)
+
+	key := APIKey{}
+	err := db.GetContext(ctx, &key, `
+		INSERT INTO api_keys (key_id, name, key_hash, scopes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *`,
+		NewULID(), name, HashAPIKey(raw), pq.StringArray(scopes),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	return raw, &key, nil
+}
+
+// FindActiveAPIKey looks up an unrevoked key by its plaintext and records its
+// use, refreshing last_used_at at most once per lastUsedResolution. The key
+// is returned as it was before the refresh.
+func FindActiveAPIKey(ctx context.Context, db *DB, raw string) (*APIKey, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	key := APIKey{}
+	err := db.GetContext(ctx, &key, `
+		WITH found AS (
+			SELECT * FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL
+		), touched AS (
+			UPDATE api_keys SET last_used_at = now()
+			WHERE key_id IN (SELECT key_id FROM found)
+			  AND (last_used_at IS NULL OR last_used_at < now() - make_interval(secs => $2))
+		)
+		SELECT * FROM found`,
+		HashAPIKey(raw), lastUsedResolution.Seconds(),
+	)
+	return &key, err
+}
+
+// ListAPIKeys returns all keys, newest first
+func ListAPIKeys(ctx context.Context, db *DB) ([]APIKey, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	keys := []APIKey{}
+	err := db.SelectContext(ctx, &keys, "SELECT * FROM api_keys ORDER BY created_at DESC")
+	return keys, err
+}
+
+// RevokeAPIKey disables a key; it returns false if no active key matched
+func RevokeAPIKey(ctx context.Context, db *DB, id string) (bool, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = now() WHERE key_id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// InsertAuditRecord appends to the admin audit log
+func InsertAuditRecord(ctx context.Context, db *DB, rec AuditRecord) error {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO admin_audit_log (audit_id, key_id, action, method, path, status_code, remote_ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		NewULID(), rec.KeyID, rec.Action, rec.Method, rec.Path, rec.StatusCode, rec.RemoteIP,
+	)
+	return err
+}
+
+// ListAuditRecords returns the most recent audit entries
+func ListAuditRecords(ctx context.Context, db *DB, limit int) ([]AuditRecord, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	records := []AuditRecord{}
+	err := db.SelectContext(ctx, &records, "SELECT * FROM admin_audit_log ORDER BY occurred_at DESC LIMIT $1", limit)
+	return records, err
+}
@@ -0,0 +1,312 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// calibrationLockKey is the advisory lock a replica holds while it fits and
+// stores a calibration
+const calibrationLockKey = 0x63616c6962 // "calib"
+
+// ErrCalibrationLocked is returned by CalibrateETAs when another replica is
+// already fitting a calibration
+var ErrCalibrationLocked = errors.New("calibration is running on another replica")
+
+// reliabilityBuckets is how many equal-width confidence bands estimates are
+// grouped into, both for the reliability report and for fitting
+const reliabilityBuckets = 10
+
+// ReliabilityBucket compares the confidence given to the estimates in one
+// band with how often those refunds actually arrived on time
+type ReliabilityBucket struct {
+	Bucket         int     `db:"bucket" json:"-"`
+	Lower          float64 `db:"-" json:"lower"`
+	Upper          float64 `db:"-" json:"upper"`
+	Predictions    int     `db:"predictions" json:"predictions"`
+	MeanConfidence float64 `db:"mean_confidence" json:"mean_confidence"`
+	// OnTimeRate is the share of refunds that arrived on or before the estimate
+	OnTimeRate float64 `db:"on_time_rate" json:"on_time_rate"`
+}
+
+// ReliabilityReport shows how well stated confidence matched reality for
+// completed returns
+type ReliabilityReport struct {
+	Predictions int `json:"predictions"`
+	// CalibrationError is the gap between mean confidence and on-time rate,
+	// averaged over buckets weighted by their predictions; zero is perfect
+	CalibrationError float64             `json:"calibration_error"`
+	Buckets          []ReliabilityBucket `json:"buckets"`
+}
+
+// ETAReliabilityReport buckets the estimates made for completed returns by
+// the confidence users were shown and compares each bucket with its on-time
+// rate. A taxYear of zero reports every year.
+func ETAReliabilityReport(ctx context.Context, db *DB, taxYear int) (*ReliabilityReport, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	buckets, err := reliability(ctx, db, taxYear, false)
+	if err != nil {
+		return nil, err
+	}
+	report := &ReliabilityReport{Buckets: buckets}
+	for _, b := range buckets {
+		report.Predictions += b.Predictions
+		report.CalibrationError += float64(b.Predictions) * math.Abs(b.MeanConfidence-b.OnTimeRate)
+	}
+	if report.Predictions > 0 {
+		report.CalibrationError /= float64(report.Predictions)
+	}
+	return report, nil
+}
+
+// reliability groups the estimates for completed returns into confidence
+// buckets, by the engine's raw confidence or by the calibrated one users saw
+func reliability(ctx context.Context, q sqlx.QueryerContext, taxYear int, raw bool) ([]ReliabilityBucket, error) {
+	column := "v.confidence"
+	if raw {
+		column = "v.raw_confidence"
+	}
+	buckets := []ReliabilityBucket{}
+	err := sqlx.SelectContext(ctx, q, &buckets, completedReturnsCTE+fmt.Sprintf(`
+		SELECT least(width_bucket(%[1]s, 0, 1, $2), $2) AS bucket,
+			count(*) AS predictions,
+			avg(%[1]s)::float8 AS mean_confidence,
+			avg(CASE WHEN c.completed_on <= v.eta_date THEN 1 ELSE 0 END)::float8 AS on_time_rate
+		FROM eta_revisions v
+		JOIN completed c ON c.return_id = v.return_id
+		WHERE v.stage <> 'COMPLETED' AND c.completed_on IS NOT NULL
+		GROUP BY 1
+		ORDER BY 1`, column),
+		taxYear, reliabilityBuckets,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for i := range buckets {
+		buckets[i].Lower = float64(buckets[i].Bucket-1) / reliabilityBuckets
+		buckets[i].Upper = float64(buckets[i].Bucket) / reliabilityBuckets
+	}
+	return buckets, nil
+}
+
+// calibrationInputsHash fingerprints the estimates reliability fits on: every
+// revision for a completed return with its raw confidence and estimate, and
+// the day the return completed. Revisions are never updated, so a changed
+// hash means different estimates or completions.
+func calibrationInputsHash(ctx context.Context, q sqlx.QueryerContext) (string, error) {
+	var hash string
+	err := sqlx.GetContext(ctx, q, &hash, completedReturnsCTE+`
+		SELECT coalesce(md5(string_agg(
+			concat_ws(':', v.id, v.raw_confidence, v.eta_date, c.completed_on), ',' ORDER BY v.id)), '')
+		FROM eta_revisions v
+		JOIN completed c ON c.return_id = v.return_id
+		WHERE v.stage <> 'COMPLETED' AND c.completed_on IS NOT NULL`,
+		0,
+	)
+	return hash, err
+}
+
+// CalibrationPoint maps a raw confidence to the on-time rate observed for it
+type CalibrationPoint struct {
+	Raw        float64 `json:"raw"`
+	Calibrated float64 `json:"calibrated"`
+}
+
+// CalibrationPoints are stored as JSONB, in ascending order of Raw
+type CalibrationPoints []CalibrationPoint
+
+// Scan implements sql.Scanner
+func (p *CalibrationPoints) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("calibration points: cannot scan %T", src)
+	}
+}
+
+// Value implements driver.Valuer
+func (p CalibrationPoints) Value() (driver.Value, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	// Sent as text so the driver doesn't encode it as bytea
+	return string(data), nil
+}
+
+// Calibration is a fitted mapping from the engine's raw confidence to the
+// on-time rate estimates with that confidence actually achieved
+type Calibration struct {
+	ID          int64             `db:"id" json:"id"`
+	Predictions int               `db:"predictions" json:"predictions"`
+	Points      CalibrationPoints `db:"points" json:"points"`
+	FittedAt    time.Time         `db:"fitted_at" json:"fitted_at"`
+	// InputsHash fingerprints the estimates fitted; nil for calibrations
+	// stored before it was recorded
+	InputsHash *string `db:"inputs_hash" json:"-"`
+}
+
+// Apply maps raw through the calibration, interpolating between points and
+// holding the end values beyond them. A nil or empty calibration returns raw.
+func (c *Calibration) Apply(raw float64) float64 {
+	if c == nil || len(c.Points) == 0 {
+		return raw
+	}
+	points := c.Points
+	if raw <= points[0].Raw {
+		return points[0].Calibrated
+	}
+	i := sort.Search(len(points), func(i int) bool { return points[i].Raw >= raw })
+	if i == len(points) {
+		return points[len(points)-1].Calibrated
+	}
+	lo, hi := points[i-1], points[i]
+	t := (raw - lo.Raw) / (hi.Raw - lo.Raw)
+	return lo.Calibrated + t*(hi.Calibrated-lo.Calibrated)
+}
+
+// FitCalibration fits a non-decreasing mapping from mean confidence to on-time
+// rate over buckets, pooling adjacent buckets whose rates run backwards so
+// more confidence never means less. Each pool becomes one point, weighted by
+// its predictions.
+func FitCalibration(buckets []ReliabilityBucket) CalibrationPoints {
+	type pool struct {
+		weight, raw, rate float64
+	}
+	sorted := append([]ReliabilityBucket(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MeanConfidence < sorted[j].MeanConfidence })
+
+	var pools []pool
+	for _, b := range sorted {
+		if b.Predictions == 0 {
+			continue
+		}
+		pools = append(pools, pool{float64(b.Predictions), b.MeanConfidence, b.OnTimeRate})
+		for n := len(pools); n > 1 && pools[n-2].rate > pools[n-1].rate; n = len(pools) {
+			lo, hi := pools[n-2], pools[n-1]
+			w := lo.weight + hi.weight
+			pools[n-2] = pool{w, (lo.raw*lo.weight + hi.raw*hi.weight) / w, (lo.rate*lo.weight + hi.rate*hi.weight) / w}
+			pools = pools[:n-1]
+		}
+	}
+
+	points := make(CalibrationPoints, len(pools))
+	for i, p := range pools {
+		points[i] = CalibrationPoint{Raw: p.raw, Calibrated: p.rate}
+	}
+	return points
+}
+
+// newCalibration fits a calibration over buckets, or returns nil when they
+// hold no predictions or fewer than minPredictions
+func newCalibration(buckets []ReliabilityBucket, minPredictions int) *Calibration {
+	c := &Calibration{Points: FitCalibration(buckets)}
+	for _, b := range buckets {
+		c.Predictions += b.Predictions
+	}
+	if c.Predictions == 0 || c.Predictions < minPredictions {
+		return nil
+	}
+	return c
+}
+
+// CalibrateETAs fits a new calibration from the raw confidence of every
+// estimate made for a completed return, stores it and has eta apply it. With
+// fewer than minPredictions estimates it leaves the current calibration in
+// place and returns nil.
+//
+// Every replica runs the job, so fits are made one at a time under an
+// advisory lock; a replica that finds the lock taken returns
+// ErrCalibrationLocked. A replica that runs after another has already fitted
+// the same estimates, judged by their inputs hash rather than their count,
+// applies that calibration instead of storing a copy.
+func CalibrateETAs(ctx context.Context, db *DB, eta *ETAEngine, minPredictions int) (*Calibration, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.GetContext(ctx, &locked, "SELECT pg_try_advisory_xact_lock($1)", calibrationLockKey); err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrCalibrationLocked
+	}
+
+	buckets, err := reliability(ctx, tx, 0, true)
+	if err != nil {
+		return nil, err
+	}
+	c := newCalibration(buckets, minPredictions)
+	if c == nil {
+		return nil, nil
+	}
+	hash, err := calibrationInputsHash(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	c.InputsHash = &hash
+
+	latest := &Calibration{}
+	err = tx.GetContext(ctx, latest, "SELECT * FROM eta_calibrations ORDER BY id DESC LIMIT 1")
+	switch {
+	case err == nil && latest.InputsHash != nil && *latest.InputsHash == hash:
+		eta.SetCalibration(latest)
+		return latest, nil
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	err = tx.GetContext(ctx, c, `
+		INSERT INTO eta_calibrations (predictions, points, inputs_hash)
+		VALUES ($1, $2, $3)
+		RETURNING *`,
+		c.Predictions, c.Points, c.InputsHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	eta.SetCalibration(c)
+	return c, nil
+}
+
+// LoadCalibration has eta apply the most recently fitted calibration, if any.
+// It runs at startup and on a schedule, so replicas pick up calibrations
+// fitted elsewhere.
+func LoadCalibration(ctx context.Context, db *DB, eta *ETAEngine) error {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	c := &Calibration{}
+	err := db.GetContext(ctx, c, "SELECT * FROM eta_calibrations ORDER BY id DESC LIMIT 1")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	eta.SetCalibration(c)
+	return nil
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"math"
+	"os"
+	"testing"
+
+	"refund-demo/internal/config"
+)
+
+func bucket(meanConfidence float64, predictions int, onTimeRate float64) ReliabilityBucket {
+	return ReliabilityBucket{MeanConfidence: meanConfidence, Predictions: predictions, OnTimeRate: onTimeRate}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestFitCalibration(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets []ReliabilityBucket
+		want    CalibrationPoints
+	}{
+		{"no buckets", nil, CalibrationPoints{}},
+		{"only empty buckets", []ReliabilityBucket{bucket(0.3, 0, 0), bucket(0.7, 0, 0)}, CalibrationPoints{}},
+		{
+			"already non-decreasing, out of order",
+			[]ReliabilityBucket{bucket(0.85, 40, 0.9), bucket(0.45, 20, 0.5), bucket(0.65, 0, 0.1), bucket(0.55, 30, 0.6)},
+			CalibrationPoints{{0.45, 0.5}, {0.55, 0.6}, {0.85, 0.9}},
+		},
+		{
+			"one inversion is pooled by weight",
+			[]ReliabilityBucket{bucket(0.55, 10, 0.8), bucket(0.65, 30, 0.6), bucket(0.95, 10, 0.9)},
+			CalibrationPoints{{0.625, 0.65}, {0.95, 0.9}},
+		},
+		{
+			"pooling cascades backwards",
+			[]ReliabilityBucket{bucket(0.1, 10, 0.5), bucket(0.2, 10, 0.4), bucket(0.3, 10, 0.3)},
+			CalibrationPoints{{0.2, 0.4}},
+		},
+		{
+			"equal rates are kept apart",
+			[]ReliabilityBucket{bucket(0.4, 5, 0.7), bucket(0.6, 5, 0.7)},
+			CalibrationPoints{{0.4, 0.7}, {0.6, 0.7}},
+		},
+	}
+	for _, tt := range tests {
+		got := FitCalibration(tt.buckets)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: FitCalibration() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !approx(got[i].Raw, tt.want[i].Raw) || !approx(got[i].Calibrated, tt.want[i].Calibrated) {
+				t.Errorf("%s: FitCalibration() = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFitCalibrationIsMonotonic(t *testing.T) {
+	// Rates that zigzag as confidence rises
+	var buckets []ReliabilityBucket
+	for i := 0; i < reliabilityBuckets; i++ {
+		rate := float64(i) / reliabilityBuckets
+		if i%2 == 1 {
+			rate = 1 - rate
+		}
+		buckets = append(buckets, bucket((float64(i)+0.5)/reliabilityBuckets, 10+i, rate))
+	}
+	c := &Calibration{Points: FitCalibration(buckets)}
+
+	for i := 1; i < len(c.Points); i++ {
+		if c.Points[i].Raw < c.Points[i-1].Raw || c.Points[i].Calibrated < c.Points[i-1].Calibrated {
+			t.Fatalf("points %v are not non-decreasing", c.Points)
+		}
+	}
+	prev := c.Apply(0)
+	for raw := 0.0; raw <= 1; raw += 0.01 {
+		got := c.Apply(raw)
+		if got < prev || math.IsNaN(got) {
+			t.Fatalf("Apply(%.2f) = %v, below Apply at lower confidence %v", raw, got, prev)
+		}
+		prev = got
+	}
+}
+
+func TestCalibrationApply(t *testing.T) {
+	fitted := &Calibration{Points: CalibrationPoints{{0.2, 0.1}, {0.6, 0.5}, {0.8, 0.9}}}
+	single := &Calibration{Points: CalibrationPoints{{0.5, 0.7}}}
+	// Pooled buckets can share a mean confidence; the mapping steps there
+	stepped := &Calibration{Points: CalibrationPoints{{0.2, 0.2}, {0.5, 0.4}, {0.5, 0.6}, {0.8, 0.8}}}
+
+	tests := []struct {
+		name string
+		c    *Calibration
+		raw  float64
+		want float64
+	}{
+		{"nil calibration", nil, 0.42, 0.42},
+		{"no points", &Calibration{}, 0.42, 0.42},
+		{"below the first point", fitted, 0.05, 0.1},
+		{"on a point", fitted, 0.6, 0.5},
+		{"interpolated", fitted, 0.4, 0.3},
+		{"interpolated near the top", fitted, 0.7, 0.7},
+		{"above the last point", fitted, 0.99, 0.9},
+		{"single point below", single, 0.1, 0.7},
+		{"single point above", single, 0.9, 0.7},
+		{"before a step", stepped, 0.35, 0.3},
+		{"on a step", stepped, 0.5, 0.4},
+		{"after a step", stepped, 0.65, 0.7},
+	}
+	for _, tt := range tests {
+		if got := tt.c.Apply(tt.raw); !approx(got, tt.want) {
+			t.Errorf("%s: Apply(%v) = %v, want %v", tt.name, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestNewCalibration(t *testing.T) {
+	buckets := []ReliabilityBucket{bucket(0.3, 60, 0.4), bucket(0.7, 140, 0.8)}
+
+	tests := []struct {
+		name           string
+		buckets        []ReliabilityBucket
+		minPredictions int
+		want           int
+	}{
+		{"no buckets", nil, 1, 0},
+		{"only empty buckets", []ReliabilityBucket{bucket(0.5, 0, 0)}, 0, 0},
+		{"fewer than the minimum", buckets, 201, 0},
+		{"exactly the minimum", buckets, 200, 200},
+		{"more than the minimum", buckets, 50, 200},
+	}
+	for _, tt := range tests {
+		c := newCalibration(tt.buckets, tt.minPredictions)
+		switch {
+		case tt.want == 0 && c != nil:
+			t.Errorf("%s: newCalibration() = %+v, want nil", tt.name, c)
+		case tt.want != 0 && (c == nil || c.Predictions != tt.want || len(c.Points) != 2):
+			t.Errorf("%s: newCalibration() = %+v, want %d predictions over 2 points", tt.name, c, tt.want)
+		}
+	}
+}
+
+// TestCalibrateETAsRefitsChangedInputs runs CalibrateETAs against a real
+// database. Set TEST_DATABASE_URL to a scratch database; it is migrated.
+func TestCalibrateETAsRefitsChangedInputs(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	dbCfg := config.Default().Database
+	dbCfg.DSN = dsn
+	dbCfg.MigrationsPath = "file://../../migrations"
+	eta, err := NewETAEngine(config.Default())
+	if err != nil {
+		t.Fatalf("NewETAEngine() = %v", err)
+	}
+	db, _ := InitPostgres(dbCfg, false, eta)
+	defer db.Close()
+
+	id, err := InsertDemoReturn(ctx, db, eta)
+	if err != nil {
+		t.Fatalf("InsertDemoReturn() = %v", err)
+	}
+	defer db.ExecContext(ctx, "DELETE FROM returns WHERE return_id=$1", id)
+	// complete marks the return completed as of days ago
+	complete := func(days int) {
+		t.Helper()
+		_, err := db.ExecContext(ctx, `
+			UPDATE returns SET status='COMPLETED',
+				history=jsonb_build_array(jsonb_build_object('stage', 'COMPLETED', 'timestamp', now() - make_interval(days => $2)))
+			WHERE return_id=$1`,
+			id, days,
+		)
+		if err != nil {
+			t.Fatalf("completing return: %v", err)
+		}
+	}
+	calibrate := func() *Calibration {
+		t.Helper()
+		c, err := CalibrateETAs(ctx, db, eta, 1)
+		if err != nil || c == nil {
+			t.Fatalf("CalibrateETAs() = %v, %v", c, err)
+		}
+		return c
+	}
+
+	complete(1)
+	first := calibrate()
+	if again := calibrate(); again.ID != first.ID {
+		t.Fatalf("unchanged estimates were refitted: calibration %d, want %d", again.ID, first.ID)
+	}
+
+	// Same number of estimates, but the return completed on another day
+	complete(90)
+	refit := calibrate()
+	if refit.ID == first.ID {
+		t.Fatalf("changed estimates with the same count were not refitted")
+	}
+	if refit.Predictions != first.Predictions {
+		t.Fatalf("refit has %d predictions, want %d", refit.Predictions, first.Predictions)
+	}
+}
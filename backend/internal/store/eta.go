@@ -0,0 +1,124 @@
+package store
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"refund-demo/internal/calendar"
+	"refund-demo/internal/config"
+	"refund-demo/internal/season"
+)
+
+// ETAEngine estimates when refunds arrive from each jurisdiction's typical
+// stage durations, the filing season and the business calendar, and how
+// confident it is in each estimate
+type ETAEngine struct {
+	Seasons  *season.Calendar
+	Calendar *calendar.Calendar
+
+	mu          sync.RWMutex
+	calibration *Calibration
+}
+
+// NewETAEngine loads the season calendar file and builds the business
+// calendar from cfg
+func NewETAEngine(cfg *config.Config) (*ETAEngine, error) {
+	seasons, err := season.Load(cfg.Season.CalendarFile)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := calendar.New(cfg.Calendar.Holidays, cfg.Calendar.Closures, calendar.DeliveryRules{
+		DepositDays:   cfg.Calendar.DepositDays,
+		CheckMailDays: cfg.Calendar.CheckMailDays,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ETAEngine{Seasons: seasons, Calendar: cal}, nil
+}
+
+// Estimate predicts when the refund of a taxYear return that entered status
+// at since will arrive. Processing runs on the jurisdiction's typical days
+// until the refund is sent; when the filing season is known the clock starts
+// no earlier than the season opens, peak weeks and holidays add days, and
+// federal refunds claiming EITC or ACTC are not sent before the PATH Act
+// release. Delivery then follows the business calendar: deposits land on
+// business days and checks spend mail days in transit.
+func (e *ETAEngine) Estimate(j *Jurisdiction, taxYear int, status string, since time.Time, sc SnapContext) time.Time {
+	switch status {
+	case StatusCompleted:
+		return since
+	case StatusSent:
+		return e.deliver(since, sc)
+	}
+
+	sent := since.AddDate(0, 0, j.TypicalDaysToSend(status))
+	if s, ok := e.Seasons.For(taxYear); ok {
+		start := s.ProcessingStart(since)
+		sent = start.AddDate(0, 0, j.TypicalDaysToSend(status))
+		sent = sent.AddDate(0, 0, s.Delay(start, sent))
+		if PATHHoldApplies(j, status, sc) && sent.Before(s.PATHRelease) {
+			sent = s.PATHRelease
+		}
+	}
+	return e.deliver(sent, sc)
+}
+
+// The engine's own confidence falls with the typical days left before the
+// refund arrives, from priorConfidenceMax for a refund about to land down to
+// priorConfidenceMin; checks can go astray in the mail, so they lose a little
+// more
+const (
+	priorConfidenceMax     = 0.98
+	priorConfidenceMin     = 0.5
+	priorConfidencePerDay  = 0.005
+	checkConfidencePenalty = 0.03
+)
+
+// Confidence is how likely a return in status is to be refunded by its
+// estimate. raw is the engine's own confidence; confidence is raw mapped
+// through the current calibration, and is what users are shown.
+func (e *ETAEngine) Confidence(j *Jurisdiction, status string, sc SnapContext) (confidence, raw float64) {
+	if status == StatusCompleted {
+		return 1, 1
+	}
+	raw = priorConfidenceMax - priorConfidencePerDay*float64(j.TypicalDaysRemaining(status))
+	if sc.RefundMethod == RefundMethodCheck {
+		raw -= checkConfidencePenalty
+	}
+	raw = math.Max(raw, priorConfidenceMin)
+	return e.Calibration().Apply(raw), raw
+}
+
+// SetCalibration replaces the calibration applied to raw confidence
+func (e *ETAEngine) SetCalibration(c *Calibration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calibration = c
+}
+
+// Calibration is the calibration currently applied, or nil if none has been
+// fitted
+func (e *ETAEngine) Calibration() *Calibration {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.calibration
+}
+
+// deliver is when a refund sent at sent reaches the taxpayer
+func (e *ETAEngine) deliver(sent time.Time, sc SnapContext) time.Time {
+	if sc.RefundMethod == RefundMethodCheck {
+		return e.Calendar.CheckDate(sent)
+	}
+	return e.Calendar.DepositDate(sent)
+}
+
+// PATHHoldApplies reports whether the PATH Act can still hold a return's
+// refund: federal refunds claiming EITC or ACTC that have not been sent
+func PATHHoldApplies(j *Jurisdiction, status string, sc SnapContext) bool {
+	if j.Code != JurisdictionFederal || !(sc.EITC || sc.ACTC) {
+		return false
+	}
+	return status != StatusSent && status != StatusCompleted
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Why a return was given a new estimate
+const (
+	ETAReasonInitial      = "initial"
+	ETAReasonStatusChange = "status_change"
+)
+
+// ETARevision is one estimate a return was given
+type ETARevision struct {
+	ID       int64  `db:"id" json:"-"`
+	ReturnID string `db:"return_id" json:"-"`
+	// Stage is the status the return was in when the estimate was made
+	Stage      string    `db:"stage" json:"stage"`
+	EtaDate    time.Time `db:"eta_date" json:"eta_date"`
+	Confidence float64   `db:"confidence" json:"confidence"`
+	// RawConfidence is the engine's confidence before calibration
+	RawConfidence float64   `db:"raw_confidence" json:"raw_confidence"`
+	Reason        string    `db:"reason" json:"reason"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// ETAChange is the last time a return's estimate moved
+type ETAChange struct {
+	PreviousEtaDate time.Time `json:"previous_eta_date"`
+	// DeltaDays is positive when the refund is now expected later
+	DeltaDays int       `json:"delta_days"`
+	Reason    string    `json:"reason"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// ListETARevisions returns a return's estimates, oldest first
+func ListETARevisions(ctx context.Context, db *DB, returnID string) ([]ETARevision, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	revisions := []ETARevision{}
+	err := db.SelectContext(ctx, &revisions, "SELECT * FROM eta_revisions WHERE return_id=$1 ORDER BY id", returnID)
+	return revisions, err
+}
+
+// insertETARevision records rev, filling in its ID
+func insertETARevision(ctx context.Context, q sqlx.QueryerContext, rev *ETARevision) error {
+	return sqlx.GetContext(ctx, q, &rev.ID, `
+		INSERT INTO eta_revisions (return_id, stage, eta_date, confidence, raw_confidence, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		rev.ReturnID, rev.Stage, rev.EtaDate, rev.Confidence, rev.RawConfidence, rev.Reason, rev.CreatedAt,
+	)
+}
+
+// lastETAChange finds the most recent revision that moved the estimate, or nil
+// if it has never moved
+func lastETAChange(revisions []ETARevision) *ETAChange {
+	for i := len(revisions) - 1; i > 0; i-- {
+		current, previous := revisions[i], revisions[i-1]
+		if current.EtaDate.Equal(previous.EtaDate) {
+			continue
+		}
+		return &ETAChange{
+			PreviousEtaDate: previous.EtaDate,
+			DeltaDays:       int(current.EtaDate.Sub(previous.EtaDate).Hours() / 24),
+			Reason:          current.Reason,
+			ChangedAt:       current.CreatedAt,
+		}
+	}
+	return nil
+}
+
+// ETAAccuracy compares the estimates made at one stage with when the refunds
+// actually arrived, for returns of one tax year
+type ETAAccuracy struct {
+	TaxYear     int    `db:"tax_year" json:"tax_year"`
+	Stage       string `db:"stage" json:"stage"`
+	Predictions int    `db:"predictions" json:"predictions"`
+	// Errors are actual minus predicted, in days; positive means late
+	MeanErrorDays    float64 `db:"mean_error_days" json:"mean_error_days"`
+	MeanAbsErrorDays float64 `db:"mean_abs_error_days" json:"mean_abs_error_days"`
+	P90AbsErrorDays  float64 `db:"p90_abs_error_days" json:"p90_abs_error_days"`
+	// OnTimeRate is the share of refunds that arrived on or before the estimate
+	OnTimeRate float64 `db:"on_time_rate" json:"on_time_rate"`
+}
+
+// completedReturnsCTE names the completed returns of tax year $1 (or every
+// year when $1 is zero) with the day they completed, as "completed"
+const completedReturnsCTE = `
+	WITH completed AS (
+		SELECT r.return_id, r.tax_year,
+			(SELECT max((h->>'timestamp')::timestamptz) FROM jsonb_array_elements(r.history) h
+			 WHERE h->>'stage' = 'COMPLETED')::date AS completed_on
+		FROM returns r
+		WHERE r.status = 'COMPLETED' AND ($1 = 0 OR r.tax_year = $1)
+	)`
+
+// ETAAccuracyReport compares every estimate made for completed returns with
+// the date they completed, grouped by tax year and the stage the estimate was
+// made at. Estimates made once a return was already complete are left out. A
+// taxYear of zero reports every year.
+func ETAAccuracyReport(ctx context.Context, db *DB, taxYear int) ([]ETAAccuracy, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	rows := []ETAAccuracy{}
+	err := db.SelectContext(ctx, &rows, completedReturnsCTE+`
+		SELECT c.tax_year, v.stage,
+			count(*) AS predictions,
+			avg(c.completed_on - v.eta_date)::float8 AS mean_error_days,
+			avg(abs(c.completed_on - v.eta_date))::float8 AS mean_abs_error_days,
+			percentile_cont(0.9) WITHIN GROUP (ORDER BY abs(c.completed_on - v.eta_date))::float8 AS p90_abs_error_days,
+			avg(CASE WHEN c.completed_on <= v.eta_date THEN 1 ELSE 0 END)::float8 AS on_time_rate
+		FROM eta_revisions v
+		JOIN completed c ON c.return_id = v.return_id
+		WHERE v.stage <> 'COMPLETED' AND c.completed_on IS NOT NULL
+		GROUP BY c.tax_year, v.stage
+		ORDER BY c.tax_year, array_position($2::text[], v.stage)`,
+		taxYear, pq.StringArray(Statuses),
+	)
+	return rows, err
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"refund-demo/internal/money"
+
+	"github.com/rs/zerolog"
+)
+
+// FilingView combines the federal and state returns from one filing
+type FilingView struct {
+	FilingID string `json:"filing_id"`
+	// Returns holds the federal return first, then states by code
+	Returns []RefundReturn `json:"returns"`
+	// Total adds up every return's refund; omitted unless all amounts are
+	// known and share a currency
+	Total *RefundSummary `json:"total,omitempty"`
+	// EtaDate is when the last outstanding refund is expected; nil once
+	// every refund has been received
+	EtaDate *time.Time `json:"eta_date"`
+	// Completed is true once every refund has been received
+	Completed bool `json:"completed"`
+}
+
+// ListFilingOwners returns the owner of each return in a filing, so access can
+// be checked before any details are loaded. It returns sql.ErrNoRows if the
+// filing has no returns.
+func ListFilingOwners(ctx context.Context, db *DB, filingID string) ([]*string, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	var owners []*string
+	if err := db.SelectContext(ctx, &owners, "SELECT owner_id FROM returns WHERE filing_id=$1", filingID); err != nil {
+		return nil, err
+	}
+	if len(owners) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return owners, nil
+}
+
+// GetFilingDetails loads every return in a filing with its details. It
+// returns sql.ErrNoRows if the filing has no returns.
+func GetFilingDetails(ctx context.Context, db *DB, filingID string) (*FilingView, error) {
+	// The timeout covers this query alone; loadReturnDetails makes several
+	// calls per return, each bounded separately like GetReturnDetails
+	queryCtx, cancel := db.withQueryTimeout(ctx)
+	var returns []RefundReturn
+	err := db.SelectContext(queryCtx, &returns, `
+		SELECT * FROM returns WHERE filing_id=$1
+		ORDER BY jurisdiction <> 'federal', jurisdiction`, filingID)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if len(returns) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	view := &FilingView{FilingID: filingID, Returns: returns, Completed: true}
+	for i := range view.Returns {
+		r := &view.Returns[i]
+		if err := loadReturnDetails(ctx, db, r); err != nil {
+			return nil, err
+		}
+		if r.Status != StatusCompleted {
+			view.Completed = false
+		}
+	}
+	view.EtaDate = filingETA(view.Returns)
+
+	total, err := totalRefunds(view.Returns)
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Str("filing_id", filingID).Msg("cannot total refunds across jurisdictions")
+	}
+	view.Total = total
+	return view, nil
+}
+
+// filingETA is the latest ETA among returns still outstanding, or nil if
+// none are. Completed returns keep the ETA they were last given, which must
+// not push the filing's date out.
+func filingETA(returns []RefundReturn) *time.Time {
+	var eta *time.Time
+	for i := range returns {
+		r := &returns[i]
+		if r.Status == StatusCompleted || r.EtaDate == nil {
+			continue
+		}
+		if eta == nil || r.EtaDate.After(*eta) {
+			eta = r.EtaDate
+		}
+	}
+	return eta
+}
+
+// totalRefunds sums the refund summaries of returns, or returns nil if any
+// return has no summary
+func totalRefunds(returns []RefundReturn) (*RefundSummary, error) {
+	if len(returns) == 0 || returns[0].Refund == nil {
+		return nil, nil
+	}
+	currency := returns[0].Refund.Original.Currency
+	total := RefundSummary{
+		Original:  money.New(0, currency),
+		Adjusted:  money.New(0, currency),
+		Paid:      money.New(0, currency),
+		Remaining: money.New(0, currency),
+	}
+	for i := range returns {
+		s := returns[i].Refund
+		if s == nil {
+			return nil, nil
+		}
+		var err error
+		if total.Original, err = total.Original.Add(s.Original); err != nil {
+			return nil, err
+		}
+		if total.Adjusted, err = total.Adjusted.Add(s.Adjusted); err != nil {
+			return nil, err
+		}
+		if total.Paid, err = total.Paid.Add(s.Paid); err != nil {
+			return nil, err
+		}
+		if total.Remaining, err = total.Remaining.Add(s.Remaining); err != nil {
+			return nil, err
+		}
+	}
+	return &total, nil
+}
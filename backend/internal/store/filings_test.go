@@ -0,0 +1,35 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilingETA(t *testing.T) {
+	day := func(d int) *time.Time {
+		t := time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	ret := func(status string, eta *time.Time) RefundReturn {
+		return RefundReturn{Status: status, EtaDate: eta}
+	}
+
+	tests := []struct {
+		name    string
+		returns []RefundReturn
+		want    *time.Time
+	}{
+		{"no returns", nil, nil},
+		{"latest outstanding", []RefundReturn{ret(StatusAccepted, day(10)), ret(StatusReview, day(20))}, day(20)},
+		{"completed return with a later ETA is ignored", []RefundReturn{ret(StatusSent, day(10)), ret(StatusCompleted, day(25))}, day(10)},
+		{"all completed", []RefundReturn{ret(StatusCompleted, day(10)), ret(StatusCompleted, day(12))}, nil},
+		{"outstanding without an ETA", []RefundReturn{ret(StatusFiled, nil), ret(StatusCompleted, day(12))}, nil},
+		{"mixed", []RefundReturn{ret(StatusFiled, nil), ret(StatusApproved, day(8)), ret(StatusCompleted, day(30))}, day(8)},
+	}
+	for _, tt := range tests {
+		got := filingETA(tt.returns)
+		if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+			t.Errorf("%s: filingETA() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package store
+
+// JurisdictionFederal is the jurisdiction of IRS returns; state returns use
+// their USPS code
+const JurisdictionFederal = "federal"
+
+// JurisdictionStage is one stage a jurisdiction's returns move through, with
+// how long returns typically spend in it
+type JurisdictionStage struct {
+	Stage       string `json:"stage"`
+	TypicalDays int    `json:"typical_days"`
+}
+
+// Jurisdiction describes the refund process of the IRS or one state
+type Jurisdiction struct {
+	Code string
+	Name string
+	// Stages are in the order a return normally moves through them; REVIEW is
+	// a detour taken after ACCEPTED
+	Stages []JurisdictionStage
+}
+
+// federalStages follow the IRS tracker: most e-filed refunds are issued
+// within 21 days of acceptance, and direct deposits land within five days
+var federalStages = []JurisdictionStage{
+	{Stage: StatusFiled, TypicalDays: 1},
+	{Stage: StatusAccepted, TypicalDays: 14},
+	{Stage: StatusApproved, TypicalDays: 3},
+	{Stage: StatusReview, TypicalDays: 60},
+	{Stage: StatusSent, TypicalDays: 5},
+	{Stage: StatusCompleted},
+}
+
+// stateStages is the default for states, whose trackers go straight from
+// processing to issuing the refund without a separate approval stage
+var stateStages = []JurisdictionStage{
+	{Stage: StatusFiled, TypicalDays: 2},
+	{Stage: StatusAccepted, TypicalDays: 21},
+	{Stage: StatusReview, TypicalDays: 45},
+	{Stage: StatusSent, TypicalDays: 7},
+	{Stage: StatusCompleted},
+}
+
+// stateAcceptedDays overrides the processing time for states that publish a
+// notably different e-file timeline
+var stateAcceptedDays = map[string]int{
+	"GA": 42,
+	"NJ": 28,
+}
+
+// incomeTaxStates names the states (and DC) that have a personal income tax
+// return; the others never issue a state refund
+var incomeTaxStates = map[string]string{
+	"AL": "Alabama", "AZ": "Arizona", "AR": "Arkansas", "CA": "California",
+	"CO": "Colorado", "CT": "Connecticut", "DE": "Delaware", "DC": "District of Columbia",
+	"GA": "Georgia", "HI": "Hawaii", "ID": "Idaho", "IL": "Illinois",
+	"IN": "Indiana", "IA": "Iowa", "KS": "Kansas", "KY": "Kentucky",
+	"LA": "Louisiana", "ME": "Maine", "MD": "Maryland", "MA": "Massachusetts",
+	"MI": "Michigan", "MN": "Minnesota", "MS": "Mississippi", "MO": "Missouri",
+	"MT": "Montana", "NE": "Nebraska", "NJ": "New Jersey", "NM": "New Mexico",
+	"NY": "New York", "NC": "North Carolina", "ND": "North Dakota", "OH": "Ohio",
+	"OK": "Oklahoma", "OR": "Oregon", "PA": "Pennsylvania", "RI": "Rhode Island",
+	"SC": "South Carolina", "UT": "Utah", "VT": "Vermont", "VA": "Virginia",
+	"WV": "West Virginia", "WI": "Wisconsin",
+}
+
+// LookupJurisdiction returns the jurisdiction for code, or false if it is
+// neither federal nor a state with an income tax return
+func LookupJurisdiction(code string) (*Jurisdiction, bool) {
+	if code == JurisdictionFederal {
+		return &Jurisdiction{Code: code, Name: "IRS", Stages: federalStages}, true
+	}
+	name, ok := incomeTaxStates[code]
+	if !ok {
+		return nil, false
+	}
+	stages := stateStages
+	if days, ok := stateAcceptedDays[code]; ok {
+		stages = make([]JurisdictionStage, len(stateStages))
+		copy(stages, stateStages)
+		for i := range stages {
+			if stages[i].Stage == StatusAccepted {
+				stages[i].TypicalDays = days
+			}
+		}
+	}
+	return &Jurisdiction{Code: code, Name: name, Stages: stages}, true
+}
+
+// HasStage reports whether returns in j can be in status
+func (j *Jurisdiction) HasStage(status string) bool {
+	for _, s := range j.Stages {
+		if s.Stage == status {
+			return true
+		}
+	}
+	return false
+}
+
+// TypicalDaysRemaining estimates the days until a return in status is
+// completed, following the normal path; returns in REVIEW resume after
+// ACCEPTED once the review ends
+func (j *Jurisdiction) TypicalDaysRemaining(status string) int {
+	days := 0
+	counting := false
+	for _, s := range j.Stages {
+		switch {
+		case s.Stage == status:
+			counting = true
+			days += s.TypicalDays
+		case s.Stage == StatusReview:
+			// Not on the normal path
+		case counting:
+			days += s.TypicalDays
+		case status == StatusReview && s.Stage == StatusAccepted:
+			// Review is entered from ACCEPTED, so count what follows it
+			counting = true
+		}
+	}
+	return days
+}
+
+// StageDays is how long returns in j typically spend in stage
+func (j *Jurisdiction) StageDays(stage string) int {
+	for _, s := range j.Stages {
+		if s.Stage == stage {
+			return s.TypicalDays
+		}
+	}
+	return 0
+}
+
+// TypicalDaysToSend estimates the days until a return in status has its
+// refund sent; how long it then takes to arrive depends on how it is paid
+func (j *Jurisdiction) TypicalDaysToSend(status string) int {
+	if status == StatusSent || status == StatusCompleted {
+		return 0
+	}
+	return j.TypicalDaysRemaining(status) - j.StageDays(StatusSent)
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// StatusChannel is the Postgres NOTIFY channel written by the returns triggers
+const StatusChannel = "return_status"
+
+// Kinds of change named in a StatusNotification
+const (
+	NotifyTransition = "transition"
+	NotifyAdjustment = "adjustment"
+)
+
+// StatusNotification is the payload sent on StatusChannel
+type StatusNotification struct {
+	ReturnID string `json:"return_id"`
+	Status   string `json:"status"`
+	// Event is NotifyTransition or NotifyAdjustment (empty on inserts)
+	Event string `json:"event,omitempty"`
+}
+
+// StatusBroker holds a single LISTEN connection per process and fans
+// notifications out to in-process subscribers keyed by return ID.
+//
+// Subscribers receive a coalescing signal rather than the payload itself and
+// are expected to reload the return from the database. After a reconnect
+// every subscriber is signalled, since notifications may have been missed.
+type StatusBroker struct {
+	listener *pq.Listener
+
+	mu   sync.Mutex
+	subs map[string]map[chan struct{}]struct{}
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewStatusBroker connects a listener to dsn and starts dispatching notifications
+func NewStatusBroker(dsn string) (*StatusBroker, error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventConnectionAttemptFailed, pq.ListenerEventDisconnected:
+			log.Warn().Err(err).Msg("status listener connection lost")
+		case pq.ListenerEventReconnected:
+			log.Info().Msg("status listener reconnected")
+		}
+	})
+	if err := listener.Listen(StatusChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	b := &StatusBroker{
+		listener: listener,
+		subs:     make(map[string]map[chan struct{}]struct{}),
+		done:     make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.run()
+
+	log.Info().Str("channel", StatusChannel).Msg("status listener started")
+	return b, nil
+}
+
+func (b *StatusBroker) run() {
+	defer b.wg.Done()
+
+	// Ping periodically so a silently dropped connection is noticed
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case n := <-b.listener.Notify:
+			if n == nil {
+				// Reconnected: notifications may have been lost, wake everyone
+				b.broadcast()
+				continue
+			}
+			var payload StatusNotification
+			if err := json.Unmarshal([]byte(n.Extra), &payload); err != nil {
+				log.Warn().Err(err).Str("payload", n.Extra).Msg("malformed status notification")
+				continue
+			}
+			b.signal(payload.ReturnID)
+		case <-ping.C:
+			if err := b.listener.Ping(); err != nil {
+				log.Warn().Err(err).Msg("status listener ping failed")
+			}
+		}
+	}
+}
+
+// Subscribe registers interest in a return. The returned channel receives a
+// value whenever the return may have changed; call cancel when done.
+func (b *StatusBroker) Subscribe(returnID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	if b.subs[returnID] == nil {
+		b.subs[returnID] = make(map[chan struct{}]struct{})
+	}
+	b.subs[returnID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs[returnID], ch)
+		if len(b.subs[returnID]) == 0 {
+			delete(b.subs, returnID)
+		}
+	}
+	return ch, cancel
+}
+
+func (b *StatusBroker) signal(returnID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[returnID] {
+		notify(ch)
+	}
+}
+
+func (b *StatusBroker) broadcast() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, chans := range b.subs {
+		for ch := range chans {
+			notify(ch)
+		}
+	}
+}
+
+// notify does a non-blocking send; a pending signal already covers this change
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the listener
+func (b *StatusBroker) Close() error {
+	close(b.done)
+	b.wg.Wait()
+	return b.listener.Close()
+}
@@ -5,6 +5,19 @@ import (
 	"time"
 )
 
+// Refund status stages, in the order a return normally moves through them
+const (
+	StatusFiled     = "FILED"
+	StatusAccepted  = "ACCEPTED"
+	StatusApproved  = "APPROVED"
+	StatusReview    = "REVIEW"
+	StatusSent      = "SENT"
+	StatusCompleted = "COMPLETED"
+)
+
+// Statuses lists every refund stage in order
+var Statuses = []string{StatusFiled, StatusAccepted, StatusApproved, StatusReview, StatusSent, StatusCompleted}
+
 type RefundHistory struct {
 	Stage     string    `json:"stage"`
 	Timestamp time.Time `json:"timestamp"`
@@ -17,7 +30,41 @@ type RefundReturn struct {
 	EtaDate     *time.Time      `db:"eta_date" json:"eta_date"`
 	Confidence  float64         `db:"confidence" json:"confidence"`
 	HistoryJSON json.RawMessage `db:"history" json:"history"`
-	SnapContext json.RawMessage `db:"snap_context" json:"snap_context"`
+	SnapContext SnapContext     `db:"snap_context" json:"snap_context"`
 	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
 	UpdatedAt   time.Time       `db:"updated_at" json:"updated_at"`
+	Version     int             `db:"version" json:"version"`
+	OwnerID     *string         `db:"owner_id" json:"-"`
+
+	// Jurisdiction is JurisdictionFederal or a state's USPS code
+	Jurisdiction string `db:"jurisdiction" json:"jurisdiction"`
+
+	// TaxYear is the year the return reports on; it is filed during the
+	// following year's season
+	TaxYear int `db:"tax_year" json:"tax_year"`
+
+	// StalledAt is set while the return has sat in its stage far longer than
+	// normal, with StalledReason saying how long and against what threshold
+	StalledAt     *time.Time `db:"stalled_at" json:"stalled_at,omitempty"`
+	StalledReason *string    `db:"stalled_reason" json:"stalled_reason,omitempty"`
+
+	// RefundAmountCents is the refund claimed on the return, if known
+	RefundAmountCents *int64 `db:"refund_amount_cents" json:"-"`
+	Currency          string `db:"currency" json:"-"`
+
+	// Loaded by GetReturnDetails
+	Refund      *RefundSummary      `db:"-" json:"refund,omitempty"`
+	Adjustments []Adjustment        `db:"-" json:"adjustments,omitempty"`
+	Stages      []JurisdictionStage `db:"-" json:"stages,omitempty"`
+	// EtaChange is omitted until the estimate has moved
+	EtaChange *ETAChange `db:"-" json:"eta_change,omitempty"`
+}
+
+// IsValidStatus reports whether status is one of the known refund stages
+func IsValidStatus(status string) bool {
+	switch status {
+	case StatusFiled, StatusAccepted, StatusApproved, StatusReview, StatusSent, StatusCompleted:
+		return true
+	}
+	return false
 }
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// outboxLockKey is the advisory lock relays take in turn to claim a batch
+const outboxLockKey = 0x6f7574626f78 // "outbox"
+
+// OutboxEvent is a change-data event recorded by the returns triggers
+type OutboxEvent struct {
+	ID           int64           `db:"id" json:"id"`
+	EventID      string          `db:"event_id" json:"event_id"`
+	AggregateID  string          `db:"aggregate_id" json:"return_id"`
+	EventType    string          `db:"event_type" json:"event_type"`
+	Payload      json.RawMessage `db:"payload" json:"payload"`
+	CreatedAt    time.Time       `db:"created_at" json:"created_at"`
+	PublishedAt  *time.Time      `db:"published_at" json:"-"`
+	ClaimedUntil *time.Time      `db:"claimed_until" json:"-"`
+}
+
+// ClaimOutboxEvents leases up to limit pending events, in publish order, for
+// lease. Claims are made one relay at a time under an advisory lock held only
+// for the claim, and skip any return with an event leased elsewhere, so
+// events for a return are never in flight on two relays. The lease is
+// committed before returning; publish outside any transaction, then call
+// MarkEventsPublished or ReleaseOutboxEvents. It returns nothing when another
+// relay is claiming.
+func ClaimOutboxEvents(ctx context.Context, db *DB, limit int, lease time.Duration) ([]OutboxEvent, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.GetContext(ctx, &locked, "SELECT pg_try_advisory_xact_lock($1)", outboxLockKey); err != nil || !locked {
+		return nil, err
+	}
+
+	events := []OutboxEvent{}
+	err = tx.SelectContext(ctx, &events, `
+		WITH next AS (
+			SELECT id FROM outbox o
+			WHERE published_at IS NULL
+			  AND (claimed_until IS NULL OR claimed_until < now())
+			  AND NOT EXISTS (
+				SELECT 1 FROM outbox held
+				WHERE held.aggregate_id = o.aggregate_id
+				  AND held.published_at IS NULL AND held.claimed_until >= now()
+			  )
+			ORDER BY id
+			LIMIT $1
+		)
+		UPDATE outbox o
+		SET claimed_until = now() + make_interval(secs => $2)
+		FROM next
+		WHERE o.id = next.id
+		RETURNING o.*`,
+		limit, lease.Seconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// UPDATE ... RETURNING doesn't keep the CTE's order
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// MarkEventsPublished stamps published_at on the given events
+func MarkEventsPublished(ctx context.Context, db *DB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, "UPDATE outbox SET published_at = now(), claimed_until = NULL WHERE id = ANY($1)", pq.Array(ids))
+	return err
+}
+
+// ReleaseOutboxEvents gives up the lease on events that weren't published so
+// the next claim retries them
+func ReleaseOutboxEvents(ctx context.Context, db *DB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, "UPDATE outbox SET claimed_until = NULL WHERE id = ANY($1) AND published_at IS NULL", pq.Array(ids))
+	return err
+}
+
+// PurgePublishedEvents deletes events published more than retention ago
+func PurgePublishedEvents(ctx context.Context, db *DB, retention time.Duration) (int64, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, 
+		"DELETE FROM outbox WHERE published_at < now() - make_interval(secs => $1)",
+		retention.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
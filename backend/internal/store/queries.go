@@ -1,34 +1,329 @@
 package store
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
 	"time"
 
+	"refund-demo/internal/money"
+	"refund-demo/internal/season"
+
 	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records store spans; it is a no-op until a tracer provider is installed
+var tracer = otel.Tracer("refund-demo/store")
+
+var (
+	// ErrInvalidStatus is returned when a transition targets an unknown status
+	ErrInvalidStatus = errors.New("invalid refund status")
+
+	// ErrConflict is returned when a conditional update finds the row at a
+	// different version than the caller read
+	ErrConflict = errors.New("return was modified concurrently")
 )
 
-func GetReturnByID(db *sqlx.DB, id string) (*RefundReturn, error) {
+// maxWriteAttempts bounds retry-with-reload when a write races another writer
+const maxWriteAttempts = 3
+
+// conflictBackoff is the wait before the first retry of a conflicted write;
+// it doubles for each further retry, with jitter so racing writers spread out
+const conflictBackoff = 20 * time.Millisecond
+
+// GetReturnByID loads a full return, recording a span under ctx
+func GetReturnByID(ctx context.Context, db *DB, id string) (*RefundReturn, error) {
+	ctx, span := tracer.Start(ctx, "store.GetReturnByID", trace.WithAttributes(
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationName("SELECT"),
+		semconv.DBCollectionName("returns"),
+		attribute.String("return_id", id),
+	))
+	defer span.End()
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
 	r := RefundReturn{}
-	err := db.Get(&r, "SELECT * FROM returns WHERE return_id=$1", id)
+	err := db.GetContext(ctx, &r, "SELECT * FROM returns WHERE return_id=$1", id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "query failed")
+	}
 	return &r, err
 }
 
-func InsertDemoReturn(db *sqlx.DB) (string, error) {
-	now := time.Now()
-	history := []RefundHistory{
-		{Stage: "FILED", Timestamp: now},
-		{Stage: "ACCEPTED", Timestamp: now.Add(24 * time.Hour)},
+// ReturnValidator holds just the fields needed to answer conditional requests
+type ReturnValidator struct {
+	Status    string    `db:"status"`
+	Version   int       `db:"version"`
+	UpdatedAt time.Time `db:"updated_at"`
+	OwnerID   *string   `db:"owner_id"`
+}
+
+// GetReturnValidator loads a return's cache validators without the JSONB
+// columns, so unchanged polls can be answered cheaply
+func GetReturnValidator(ctx context.Context, db *DB, id string) (*ReturnValidator, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	v := ReturnValidator{}
+	err := db.GetContext(ctx, &v, "SELECT status, version, updated_at, owner_id FROM returns WHERE return_id=$1", id)
+	return &v, err
+}
+
+// CountReturnsByStatus returns how many returns are in each status; statuses
+// with no returns are absent from the map
+func CountReturnsByStatus(ctx context.Context, db *DB) (map[string]int, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	var rows []struct {
+		Status string `db:"status"`
+		Count  int    `db:"count"`
 	}
-	histJSON, _ := json.Marshal(history)
-	
+	if err := db.SelectContext(ctx, &rows, "SELECT status, count(*) AS count FROM returns GROUP BY status"); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// TransitionReturn moves a return to a new status, appends the stage to its
+// history, re-estimates its ETA with eta and records the revision, and
+// enqueues webhook deliveries, all in a single transaction. Transitioning to
+// the current status is a no-op.
+//
+// If expectedVersion is non-zero the transition only applies to that version
+// of the return and fails with ErrConflict otherwise (If-Match semantics).
+// With expectedVersion zero, a concurrent write causes the return to be
+// reloaded and the transition retried (see retryOnConflict).
+func TransitionReturn(ctx context.Context, db *DB, eta *ETAEngine, id, status string, expectedVersion int) (*RefundReturn, error) {
+	if !IsValidStatus(status) {
+		return nil, ErrInvalidStatus
+	}
+	return retryOnConflict(ctx, db, expectedVersion, func(ctx context.Context) (*RefundReturn, error) {
+		return transitionReturnOnce(ctx, db, eta, id, status, expectedVersion)
+	})
+}
+
+// retryOnConflict runs write, which reads a return without locking it and
+// updates it only at the version it read. Each attempt gets its own query
+// timeout. If expectedVersion is non-zero a conflict is returned as is;
+// otherwise write is retried after a jittered backoff, up to maxWriteAttempts
+// times. Retries are logged with the logger in ctx, which is expected to carry
+// the return_id.
+func retryOnConflict(ctx context.Context, db *DB, expectedVersion int, write func(ctx context.Context) (*RefundReturn, error)) (*RefundReturn, error) {
+	var err error
+	for attempt := 1; attempt <= maxWriteAttempts; attempt++ {
+		var r *RefundReturn
+		r, err = withAttemptTimeout(ctx, db, write)
+		if !errors.Is(err, ErrConflict) || expectedVersion != 0 || attempt == maxWriteAttempts {
+			return r, err
+		}
+
+		wait := conflictBackoff << (attempt - 1)
+		wait += rand.N(wait)
+		zerolog.Ctx(ctx).Warn().Int("attempt", attempt).Dur("backoff", wait).Msg("write conflicted, reloading")
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+// withAttemptTimeout runs one attempt of a write under its own query timeout
+func withAttemptTimeout(ctx context.Context, db *DB, write func(ctx context.Context) (*RefundReturn, error)) (*RefundReturn, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+	return write(ctx)
+}
+
+func transitionReturnOnce(ctx context.Context, db *DB, eta *ETAEngine, id, status string, expectedVersion int) (*RefundReturn, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	r := RefundReturn{}
+	if err := tx.GetContext(ctx, &r, "SELECT * FROM returns WHERE return_id=$1", id); err != nil {
+		return nil, err
+	}
+	if expectedVersion != 0 && r.Version != expectedVersion {
+		return nil, ErrConflict
+	}
+	if r.Status == status {
+		return &r, nil
+	}
+	j, known := LookupJurisdiction(r.Jurisdiction)
+	if known && !j.HasStage(status) {
+		return nil, fmt.Errorf("%w: %s returns have no %s stage", ErrInvalidStatus, j.Name, status)
+	}
+
+	var history []RefundHistory
+	if err := json.Unmarshal(r.HistoryJSON, &history); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	history = append(history, RefundHistory{Stage: status, Timestamp: now})
+	histJSON, err := json.Marshal(history)
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-estimate from the new stage; returns in an unknown jurisdiction keep theirs
+	etaDate := r.EtaDate
+	confidence, rawConfidence := r.Confidence, r.Confidence
+	if known {
+		d := eta.Estimate(j, r.TaxYear, status, now, r.SnapContext)
+		etaDate = &d
+		confidence, rawConfidence = eta.Confidence(j, status, r.SnapContext)
+	}
+
+	previous := r.Status
+	if err := updateReturnStatus(ctx, tx, &r, status, histJSON, etaDate, confidence); err != nil {
+		return nil, err
+	}
+	if r.EtaDate != nil {
+		rev := ETARevision{
+			ReturnID:      r.ReturnID,
+			Stage:         status,
+			EtaDate:       *r.EtaDate,
+			Confidence:    r.Confidence,
+			RawConfidence: rawConfidence,
+			Reason:        ETAReasonStatusChange,
+			CreatedAt:     now,
+		}
+		if err := insertETARevision(ctx, tx, &rev); err != nil {
+			return nil, err
+		}
+	}
+
+	event := StatusEvent{
+		EventID:        NewULID(),
+		EventType:      StatusEventType(status),
+		ReturnID:       r.ReturnID,
+		FilingID:       r.FilingID,
+		Jurisdiction:   r.Jurisdiction,
+		PreviousStatus: previous,
+		Status:         status,
+		OccurredAt:     now,
+	}
+	if err := enqueueStatusWebhooks(ctx, tx, event); err != nil {
+		return nil, err
+	}
+
+	return &r, tx.Commit()
+}
+
+// updateReturnStatus writes status, history, ETA and confidence only if the
+// row is still at r.Version, refreshing r with the stored row. A stall flag
+// belongs to the stage being left, so it is cleared. It returns ErrConflict
+// if another writer got there first.
+func updateReturnStatus(ctx context.Context, tx *sqlx.Tx, r *RefundReturn, status string, history json.RawMessage, etaDate *time.Time, confidence float64) error {
+	err := tx.GetContext(ctx, r, `
+		UPDATE returns SET status=$3, history=$4, eta_date=$5, confidence=$6, stalled_at=NULL, stalled_reason=NULL
+		WHERE return_id=$1 AND version=$2
+		RETURNING *`,
+		r.ReturnID, r.Version, status, history, etaDate, confidence,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrConflict
+	}
+	return err
+}
+
+// InsertDemoReturn inserts a demo filing with an approved federal return and
+// a California return still in processing, with ETAs and confidence from eta.
+// It returns the federal return's ID.
+func InsertDemoReturn(ctx context.Context, db *DB, eta *ETAEngine) (string, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
 	returnID := NewULID()
 	filingID := NewULID()
+	taxYear := season.TaxYearFiledIn(now)
+	snapContext := SnapContext{
+		Version:      SnapContextVersion,
+		FilingType:   FilingSingle,
+		State:        "CA",
+		RefundMethod: RefundMethodDirectDeposit,
+		BankLast4:    "0042",
+	}
+	// Each return's history leads up to its own status, in the past
+	returns := []struct {
+		id, jurisdiction, status string
+		cents                    int64
+		history                  []RefundHistory
+	}{
+		{returnID, JurisdictionFederal, StatusApproved, 312500, []RefundHistory{
+			{Stage: StatusFiled, Timestamp: now.AddDate(0, 0, -5)},
+			{Stage: StatusAccepted, Timestamp: now.AddDate(0, 0, -4)},
+			{Stage: StatusApproved, Timestamp: now.AddDate(0, 0, -1)},
+		}},
+		{NewULID(), "CA", StatusAccepted, 48600, []RefundHistory{
+			{Stage: StatusFiled, Timestamp: now.AddDate(0, 0, -5)},
+			{Stage: StatusAccepted, Timestamp: now.AddDate(0, 0, -3)},
+		}},
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	for _, r := range returns {
+		j, _ := LookupJurisdiction(r.jurisdiction)
+		histJSON, err := json.Marshal(r.history)
+		if err != nil {
+			return "", err
+		}
+		since := r.history[len(r.history)-1].Timestamp
+		etaDate := eta.Estimate(j, taxYear, r.status, since, snapContext)
+		confidence, _ := eta.Confidence(j, r.status, snapContext)
+		_, err = tx.ExecContext(ctx, `INSERT INTO returns 
+		(return_id, filing_id, jurisdiction, tax_year, status, eta_date, confidence, history, snap_context, owner_id, refund_amount_cents, currency) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT DO NOTHING`,
+			r.id, filingID, r.jurisdiction, taxYear, r.status, etaDate, confidence, histJSON, snapContext, DemoOwnerID, r.cents, money.USD)
+		if err != nil {
+			return "", err
+		}
+
+		// One revision per stage, as SeedDemoData records
+		for k, h := range r.history {
+			rev := ETARevision{
+				ReturnID:  r.id,
+				Stage:     h.Stage,
+				EtaDate:   eta.Estimate(j, taxYear, h.Stage, h.Timestamp, snapContext),
+				Reason:    ETAReasonStatusChange,
+				CreatedAt: h.Timestamp,
+			}
+			rev.Confidence, rev.RawConfidence = eta.Confidence(j, h.Stage, snapContext)
+			if k == 0 {
+				rev.Reason = ETAReasonInitial
+			}
+			if err := insertETARevision(ctx, tx, &rev); err != nil {
+				return "", err
+			}
+		}
+	}
 	
-	_, err := db.Exec(`INSERT INTO returns 
-	(return_id, filing_id, status, eta_date, confidence, history, snap_context) 
-	VALUES ($1, $2, $3, CURRENT_DATE + interval '10 days', 0.94, $4, '{}'::jsonb)
-	ON CONFLICT DO NOTHING`,
-		returnID, filingID, "APPROVED", histJSON)
-	
-	return returnID, err
+	return returnID, tx.Commit()
 }
@@ -1,29 +1,47 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
-	"github.com/jmoiron/sqlx"
+	"refund-demo/internal/money"
+	"refund-demo/internal/season"
+
 	"github.com/rs/zerolog/log"
 )
 
+// DemoOwnerID owns every seeded return; mint a token with this owner claim to read them
+const DemoOwnerID = "demo-user"
+
 // DemoReturn represents a demo tax return with predefined data
 type DemoReturn struct {
 	Status      string
-	EtaDate     *time.Time
-	Confidence  float64
 	History     []RefundHistory
 	Description string
+	RefundCents int64
+	Context     SnapContext
+	Adjustments []Adjustment
+
+	// Jurisdiction defaults to federal
+	Jurisdiction string
+	// StateReturns are filed alongside this federal return and share its
+	// filing, description and context
+	StateReturns []DemoReturn
 }
 
-// SeedDemoData populates the database with realistic demo data
-func SeedDemoData(db *sqlx.DB) error {
+// SeedDemoData populates the database with realistic demo data. ETAs and
+// confidence come from eta, estimated from each return's latest stage as if
+// it were live. Each filing is written in its own transaction under its own
+// query timeout, so a large seed isn't cut off by a single deadline.
+func SeedDemoData(ctx context.Context, db *DB, eta *ETAEngine) error {
 	log.Info().Msg("seeding demo data...")
 
 	// Check if data already exists
 	var count int
-	err := db.Get(&count, "SELECT COUNT(*) FROM returns")
+	countCtx, cancel := db.withQueryTimeout(ctx)
+	err := db.GetContext(countCtx, &count, "SELECT COUNT(*) FROM returns")
+	cancel()
 	if err != nil {
 		return err
 	}
@@ -38,39 +56,76 @@ func SeedDemoData(db *sqlx.DB) error {
 		// 1. Recently filed return - awaiting IRS acceptance
 		{
 			Status:      "FILED",
-			EtaDate:     datePtr(time.Now().AddDate(0, 0, 21)), // 21 days from now
-			Confidence:  0.85,
 			History:     []RefundHistory{{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -2)}},
 			Description: "Recently filed return",
+			RefundCents: 500000,
+			Context: SnapContext{
+				FilingType:   FilingSingle,
+				State:        "CA",
+				RefundMethod: RefundMethodDirectDeposit,
+				BankLast4:    "4821",
+			},
+			StateReturns: []DemoReturn{
+				// Filed with the federal return, not yet accepted
+				{
+					Jurisdiction: "CA",
+					Status:       "FILED",
+					History: []RefundHistory{
+						{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -2)},
+					},
+					RefundCents: 124000,
+				},
+			},
 		},
 		// 2. Accepted return - under review
 		{
-			Status:      "ACCEPTED",
-			EtaDate:     datePtr(time.Now().AddDate(0, 0, 14)), // 14 days from now
-			Confidence:  0.88,
+			Status: "ACCEPTED",
 			History: []RefundHistory{
 				{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -7)},
 				{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -5)},
 			},
 			Description: "Accepted and under review",
+			RefundCents: 550000,
+			Context: SnapContext{
+				FilingType:   FilingMarriedJoint,
+				State:        "TX",
+				RefundMethod: RefundMethodDirectDeposit,
+				BankLast4:    "0937",
+			},
 		},
 		// 3. Approved return - processing payment
 		{
-			Status:      "APPROVED",
-			EtaDate:     datePtr(time.Now().AddDate(0, 0, 7)), // 7 days from now
-			Confidence:  0.94,
+			Status: "APPROVED",
 			History: []RefundHistory{
 				{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -14)},
 				{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -12)},
 				{Stage: "APPROVED", Timestamp: time.Now().AddDate(0, 0, -3)},
 			},
 			Description: "Approved and payment processing",
+			RefundCents: 600000,
+			Context: SnapContext{
+				FilingType:   FilingHeadOfHousehold,
+				State:        "NY",
+				RefundMethod: RefundMethodDirectDeposit,
+				BankLast4:    "5512",
+				ACTC:         true,
+			},
+			StateReturns: []DemoReturn{
+				// State still processing after the federal refund was approved
+				{
+					Jurisdiction: "NY",
+					Status:       "ACCEPTED",
+					History: []RefundHistory{
+						{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -14)},
+						{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -11)},
+					},
+					RefundCents: 98000,
+				},
+			},
 		},
 		// 4. Sent - refund on the way
 		{
-			Status:      "SENT",
-			EtaDate:     datePtr(time.Now().AddDate(0, 0, 2)), // 2 days from now
-			Confidence:  0.98,
+			Status: "SENT",
 			History: []RefundHistory{
 				{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -21)},
 				{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -19)},
@@ -78,12 +133,23 @@ func SeedDemoData(db *sqlx.DB) error {
 				{Stage: "SENT", Timestamp: time.Now().AddDate(0, 0, -1)},
 			},
 			Description: "Refund sent - arriving soon",
+			RefundCents: 650000,
+			Context: SnapContext{
+				FilingType:   FilingSingle,
+				State:        "FL",
+				RefundMethod: RefundMethodCheck,
+			},
+			Adjustments: []Adjustment{
+				{
+					Kind: AdjustmentOffset, AmountCents: -85000, ReasonCode: ReasonChildSupport,
+					Agency: "Florida Department of Revenue", Description: "Child support case ending 8842",
+					OccurredAt: time.Now().AddDate(0, 0, -2),
+				},
+			},
 		},
 		// 5. Completed - refund received
 		{
-			Status:      "COMPLETED",
-			EtaDate:     datePtr(time.Now().AddDate(0, 0, -3)), // 3 days ago
-			Confidence:  1.0,
+			Status: "COMPLETED",
 			History: []RefundHistory{
 				{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -30)},
 				{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -28)},
@@ -92,105 +158,184 @@ func SeedDemoData(db *sqlx.DB) error {
 				{Stage: "COMPLETED", Timestamp: time.Now().AddDate(0, 0, -3)},
 			},
 			Description: "Refund completed",
+			RefundCents: 700000,
+			Context: SnapContext{
+				FilingType:   FilingMarriedJoint,
+				State:        "WA",
+				RefundMethod: RefundMethodDirectDeposit,
+				BankLast4:    "7764",
+			},
+			Adjustments: []Adjustment{
+				{Kind: AdjustmentInterest, AmountCents: 1842, Description: "Overpayment interest", OccurredAt: time.Now().AddDate(0, 0, -5)},
+			},
 		},
 		// 6. Under additional review - delayed
 		{
-			Status:      "REVIEW",
-			EtaDate:     datePtr(time.Now().AddDate(0, 0, 28)), // 28 days from now
-			Confidence:  0.72,
+			Status: "REVIEW",
 			History: []RefundHistory{
 				{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -15)},
 				{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -13)},
 				{Stage: "REVIEW", Timestamp: time.Now().AddDate(0, 0, -5)},
 			},
 			Description: "Under additional review",
+			RefundCents: 750000,
+			Context: SnapContext{
+				FilingType:   FilingHeadOfHousehold,
+				State:        "GA",
+				RefundMethod: RefundMethodDirectDeposit,
+				BankLast4:    "2290",
+				EITC:         true,
+				ACTC:         true,
+			},
+			Adjustments: []Adjustment{
+				{
+					Kind: AdjustmentCorrection, AmountCents: -40000, ReasonCode: ReasonMathError,
+					Agency: "IRS", Description: "Child Tax Credit recomputed for one qualifying child",
+					OccurredAt: time.Now().AddDate(0, 0, -5),
+				},
+			},
+			StateReturns: []DemoReturn{
+				// State refund sent while the federal return is held for review
+				{
+					Jurisdiction: "GA",
+					Status:       "SENT",
+					History: []RefundHistory{
+						{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -15)},
+						{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -13)},
+						{Stage: "SENT", Timestamp: time.Now().AddDate(0, 0, -2)},
+					},
+					RefundCents: 61000,
+				},
+			},
 		},
 		// 7. Early filer - high income
 		{
-			Status:      "ACCEPTED",
-			EtaDate:     datePtr(time.Now().AddDate(0, 0, 18)), // 18 days from now
-			Confidence:  0.91,
+			Status: "ACCEPTED",
 			History: []RefundHistory{
 				{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -10)},
 				{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -8)},
 			},
 			Description: "Early filer with high income",
+			RefundCents: 800000,
+			Context: SnapContext{
+				FilingType:   FilingMarriedJoint,
+				State:        "NJ",
+				RefundMethod: RefundMethodDirectDeposit,
+				BankLast4:    "3108",
+			},
+			StateReturns: []DemoReturn{
+				// Resident return
+				{
+					Jurisdiction: "NJ",
+					Status:       "ACCEPTED",
+					History: []RefundHistory{
+						{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -10)},
+						{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -8)},
+					},
+					RefundCents: 45000,
+				},
+				// Nonresident return for wages earned in New York
+				{
+					Jurisdiction: "NY",
+					Status:       "REVIEW",
+					History: []RefundHistory{
+						{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -10)},
+						{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -9)},
+						{Stage: "REVIEW", Timestamp: time.Now().AddDate(0, 0, -3)},
+					},
+					RefundCents: 21000,
+				},
+			},
 		},
 		// 8. Standard return - on track
 		{
-			Status:      "APPROVED",
-			EtaDate:     datePtr(time.Now().AddDate(0, 0, 10)), // 10 days from now
-			Confidence:  0.92,
+			Status: "APPROVED",
 			History: []RefundHistory{
 				{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -12)},
 				{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -10)},
 				{Stage: "APPROVED", Timestamp: time.Now().AddDate(0, 0, -2)},
 			},
 			Description: "Standard return on track",
+			RefundCents: 850000,
+			Context: SnapContext{
+				FilingType:   FilingMarriedSeparate,
+				State:        "IL",
+				RefundMethod: RefundMethodCheck,
+			},
+			StateReturns: []DemoReturn{
+				// State refund already received
+				{
+					Jurisdiction: "IL",
+					Status:       "COMPLETED",
+					History: []RefundHistory{
+						{Stage: "FILED", Timestamp: time.Now().AddDate(0, 0, -12)},
+						{Stage: "ACCEPTED", Timestamp: time.Now().AddDate(0, 0, -11)},
+						{Stage: "SENT", Timestamp: time.Now().AddDate(0, 0, -6)},
+						{Stage: "COMPLETED", Timestamp: time.Now().AddDate(0, 0, -3)},
+					},
+					RefundCents: 73000,
+				},
+			},
 		},
 	}
 
-	// Insert all demo returns
+	// Insert all demo filings
+	inserted := 0
 	for i, demoReturn := range demoReturns {
-		returnID := NewULID()
-		filingID := NewULID()
-
-		// Serialize history to JSON
-		historyJSON, err := json.Marshal(demoReturn.History)
-		if err != nil {
-			log.Error().Err(err).Int("index", i).Msg("failed to marshal history")
-			continue
-		}
-
-		// Create snapshot context with demo metadata
-		snapContext := map[string]interface{}{
-			"demo":        true,
-			"description": demoReturn.Description,
-			"scenario":    i + 1,
-			"amount":      5000 + (i * 500), // Varying refund amounts
-		}
-		snapJSON, err := json.Marshal(snapContext)
+		// Demo metadata rides along as extras next to the typed fields
+		snapContext := demoReturn.Context
+		snapContext.Version = SnapContextVersion
+		snapContext.Extras, err = demoExtras(demoReturn.Description, i+1)
 		if err != nil {
 			log.Error().Err(err).Int("index", i).Msg("failed to marshal snap_context")
 			continue
 		}
 
-		// Insert into database
-		_, err = db.Exec(`
-			INSERT INTO returns 
-			(return_id, filing_id, status, eta_date, confidence, history, snap_context) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-			returnID, filingID, demoReturn.Status, demoReturn.EtaDate,
-			demoReturn.Confidence, historyJSON, snapJSON,
-		)
-
+		// Insert the federal and state returns and their adjustments together
+		filingID := NewULID()
+		returnIDs, err := insertDemoFiling(ctx, db, eta, filingID, demoReturn, snapContext)
 		if err != nil {
 			log.Error().Err(err).Int("index", i).Str("status", demoReturn.Status).Msg("failed to insert demo return")
 			continue
 		}
 
-		log.Info().
-			Int("scenario", i+1).
-			Str("return_id", returnID).
-			Str("status", demoReturn.Status).
-			Str("description", demoReturn.Description).
-			Msg("inserted demo return")
+		for j, returnID := range returnIDs {
+			r := demoReturn
+			if j > 0 {
+				r = demoReturn.StateReturns[j-1]
+			}
+			log.Info().
+				Int("scenario", i+1).
+				Str("filing_id", filingID).
+				Str("return_id", returnID).
+				Str("jurisdiction", jurisdictionOrFederal(r.Jurisdiction)).
+				Str("status", r.Status).
+				Str("description", demoReturn.Description).
+				Msg("inserted demo return")
+		}
+		inserted += len(returnIDs)
 	}
 
-	log.Info().Int("count", len(demoReturns)).Msg("demo data seeded successfully")
+	log.Info().Int("filings", len(demoReturns)).Int("count", inserted).Msg("demo data seeded successfully")
 	return nil
 }
 
 // GetAllReturns retrieves all returns from the database
-func GetAllReturns(db *sqlx.DB) ([]RefundReturn, error) {
+func GetAllReturns(ctx context.Context, db *DB) ([]RefundReturn, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
 	var returns []RefundReturn
-	err := db.Select(&returns, "SELECT * FROM returns ORDER BY created_at DESC")
+	err := db.SelectContext(ctx, &returns, "SELECT * FROM returns ORDER BY created_at DESC")
 	return returns, err
 }
 
 // ClearAllReturns removes all returns from the database (use with caution!)
-func ClearAllReturns(db *sqlx.DB) error {
-	_, err := db.Exec("DELETE FROM returns")
+func ClearAllReturns(ctx context.Context, db *DB) error {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, "DELETE FROM returns")
 	if err != nil {
 		return err
 	}
@@ -198,7 +343,97 @@ func ClearAllReturns(db *sqlx.DB) error {
 	return nil
 }
 
-// Helper function to create a date pointer
-func datePtr(t time.Time) *time.Time {
-	return &t
+// demoExtras tags a seeded context so demo rows can be found and cleared
+func demoExtras(description string, scenario int) (map[string]json.RawMessage, error) {
+	extras := map[string]interface{}{
+		"demo":        true,
+		"description": description,
+		"scenario":    scenario,
+	}
+	out := make(map[string]json.RawMessage, len(extras))
+	for k, v := range extras {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = raw
+	}
+	return out, nil
+}
+
+// insertDemoFiling writes a seeded federal return, its state returns and all
+// their adjustments in one transaction, returning the IDs in that order
+func insertDemoFiling(ctx context.Context, db *DB, eta *ETAEngine, filingID string, demoReturn DemoReturn, snapContext SnapContext) ([]string, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Seeded returns belong to the season under way
+	taxYear := season.TaxYearFiledIn(time.Now())
+
+	var ids []string
+	for _, r := range append([]DemoReturn{demoReturn}, demoReturn.StateReturns...) {
+		returnID := NewULID()
+		historyJSON, err := json.Marshal(r.History)
+		if err != nil {
+			return nil, err
+		}
+		j, _ := LookupJurisdiction(jurisdictionOrFederal(r.Jurisdiction))
+		since := r.History[len(r.History)-1].Timestamp
+		etaDate := eta.Estimate(j, taxYear, r.Status, since, snapContext)
+		confidence, _ := eta.Confidence(j, r.Status, snapContext)
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO returns 
+			(return_id, filing_id, jurisdiction, tax_year, status, eta_date, confidence, history, snap_context, owner_id, refund_amount_cents, currency) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			returnID, filingID, jurisdictionOrFederal(r.Jurisdiction), taxYear, r.Status, etaDate,
+			confidence, historyJSON, snapContext, DemoOwnerID,
+			r.RefundCents, money.USD,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Replay the estimate the return would have been given at each stage,
+		// so seeded returns have an ETA history like live ones
+		for k, h := range r.History {
+			rev := ETARevision{
+				ReturnID:  returnID,
+				Stage:     h.Stage,
+				EtaDate:   eta.Estimate(j, taxYear, h.Stage, h.Timestamp, snapContext),
+				Reason:    ETAReasonStatusChange,
+				CreatedAt: h.Timestamp,
+			}
+			rev.Confidence, rev.RawConfidence = eta.Confidence(j, h.Stage, snapContext)
+			if k == 0 {
+				rev.Reason = ETAReasonInitial
+			}
+			if err := insertETARevision(ctx, tx, &rev); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, adj := range r.Adjustments {
+			adj.ReturnID = returnID
+			adj.Currency = money.USD
+			if err := insertAdjustment(ctx, tx, &adj); err != nil {
+				return nil, err
+			}
+		}
+		ids = append(ids, returnID)
+	}
+	return ids, tx.Commit()
+}
+
+func jurisdictionOrFederal(code string) string {
+	if code == "" {
+		return JurisdictionFederal
+	}
+	return code
 }
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SnapContextVersion is the schema version written to snap_context. Rows
+// written under an older version are upgraded as they are read.
+const SnapContextVersion = 3
+
+// Filing statuses recorded on the return
+const (
+	FilingSingle              = "single"
+	FilingMarriedJoint        = "married_joint"
+	FilingMarriedSeparate     = "married_separate"
+	FilingHeadOfHousehold     = "head_of_household"
+	FilingQualifyingSurviving = "qualifying_surviving_spouse"
+)
+
+// How the refund is paid out
+const (
+	RefundMethodDirectDeposit = "direct_deposit"
+	RefundMethodCheck         = "check"
+)
+
+// ErrInvalidSnapContext is returned when a snap_context fails validation on write
+var ErrInvalidSnapContext = errors.New("invalid snap_context")
+
+// SnapContext is the snapshot of filing details stored with a return. The
+// typed fields are validated on write; any other keys are carried through
+// untouched in Extras and stored alongside them in the same JSON object, so
+// existing queries like snap_context->>'description' keep working.
+type SnapContext struct {
+	Version    int    `json:"version"`
+	FilingType string `json:"filing_type,omitempty"`
+	// State is the two-letter USPS code of the state of residence, if any
+	State        string `json:"state,omitempty"`
+	RefundMethod string `json:"refund_method,omitempty"`
+	// BankLast4 holds only the last four digits of the deposit account
+	BankLast4 string `json:"bank_last4,omitempty"`
+	// EITC and ACTC flag the credits that hold refunds until mid-February
+	EITC bool `json:"eitc"`
+	ACTC bool `json:"actc"`
+
+	Extras map[string]json.RawMessage `json:"-"`
+}
+
+// snapContextFields are the typed keys; everything else is an extra
+var snapContextFields = []string{
+	"version", "filing_type", "state",
+	"refund_method", "bank_last4", "eitc", "actc",
+}
+
+// sensitiveExtras must never be stored; the typed fields hold the safe form
+var sensitiveExtras = []string{"ssn", "tin", "account_number", "bank_account", "routing_number"}
+
+var filingTypes = map[string]bool{
+	FilingSingle: true, FilingMarriedJoint: true, FilingMarriedSeparate: true,
+	FilingHeadOfHousehold: true, FilingQualifyingSurviving: true,
+}
+
+// stateCodes are the USPS codes for the states, DC and territories
+var stateCodes = map[string]bool{}
+
+func init() {
+	for _, code := range strings.Fields(`AL AK AZ AR CA CO CT DE FL GA HI ID IL IN IA KS KY LA ME MD
+		MA MI MN MS MO MT NE NV NH NJ NM NY NC ND OH OK OR PA RI SC SD TN TX UT VT VA WA WV WI WY
+		DC AS GU MP PR VI`) {
+		stateCodes[code] = true
+	}
+}
+
+// snapContextUpgrades moves a decoded document from the keyed version to the
+// next one. Every version below SnapContextVersion needs an entry.
+var snapContextUpgrades = map[int]func(fields map[string]json.RawMessage) error{
+	1: upgradeSnapContextV1,
+	2: upgradeSnapContextV2,
+}
+
+// upgradeSnapContextV1 handles the untyped documents written before
+// versioning, which carried the refund as dollars in "amount". Nothing was
+// validated then, so keys Validate now rejects are dropped rather than
+// leaving the row impossible to write back.
+func upgradeSnapContextV1(fields map[string]json.RawMessage) error {
+	for _, key := range sensitiveExtras {
+		delete(fields, key)
+	}
+
+	raw, ok := fields["amount"]
+	if !ok {
+		return nil
+	}
+	var dollars float64
+	if err := json.Unmarshal(raw, &dollars); err != nil {
+		// Not a number; leave it as an extra rather than guess
+		return nil
+	}
+	cents, err := json.Marshal(int64(math.Round(dollars * 100)))
+	if err != nil {
+		return err
+	}
+	fields["refund_amount_cents"] = cents
+	delete(fields, "amount")
+	return nil
+}
+
+// upgradeSnapContextV2 drops the refund amount, which now lives in
+// returns.refund_amount_cents; migration 000009 copied existing amounts there
+func upgradeSnapContextV2(fields map[string]json.RawMessage) error {
+	delete(fields, "refund_amount_cents")
+	return nil
+}
+
+// NewSnapContext returns an empty context at the current version
+func NewSnapContext() SnapContext {
+	return SnapContext{Version: SnapContextVersion}
+}
+
+// Validate checks the typed fields. Older versions are upgraded on read, so
+// only the current version may be written.
+func (s *SnapContext) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(s.Version == SnapContextVersion, "version %d must be %d", s.Version, SnapContextVersion)
+	check(s.FilingType == "" || filingTypes[s.FilingType], "filing_type %q is not a known filing status", s.FilingType)
+	check(s.State == "" || stateCodes[s.State], "state %q is not a USPS state code", s.State)
+	check(s.RefundMethod == "" || s.RefundMethod == RefundMethodDirectDeposit || s.RefundMethod == RefundMethodCheck,
+		"refund_method %q must be %s or %s", s.RefundMethod, RefundMethodDirectDeposit, RefundMethodCheck)
+	check(s.BankLast4 == "" || isDigits(s.BankLast4, 4), "bank_last4 must be exactly four digits")
+	check(s.BankLast4 == "" || s.RefundMethod == RefundMethodDirectDeposit, "bank_last4 is only allowed with direct_deposit")
+	for _, key := range sensitiveExtras {
+		_, present := s.Extras[key]
+		check(!present, "%s must not be stored", key)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidSnapContext, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// MaskedAccount renders the deposit account for display, e.g. "****1234"
+func (s *SnapContext) MaskedAccount() string {
+	if s.BankLast4 == "" {
+		return ""
+	}
+	return "****" + s.BankLast4
+}
+
+// UnmarshalJSON decodes a stored document of any version, upgrading it to
+// the current one and keeping unknown keys in Extras
+func (s *SnapContext) UnmarshalJSON(data []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	// Documents without a version predate versioning
+	version := 1
+	if raw, ok := fields["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return fmt.Errorf("snap_context version: %w", err)
+		}
+	}
+	for ; version < SnapContextVersion; version++ {
+		upgrade, ok := snapContextUpgrades[version]
+		if !ok {
+			return fmt.Errorf("snap_context: no upgrade from version %d", version)
+		}
+		if err := upgrade(fields); err != nil {
+			return fmt.Errorf("snap_context: upgrade from version %d: %w", version, err)
+		}
+	}
+	fields["version"] = json.RawMessage(fmt.Sprint(version))
+
+	// Decode the typed fields through an alias so this method isn't re-entered
+	type typed SnapContext
+	known, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	var t typed
+	if err := json.Unmarshal(known, &t); err != nil {
+		return fmt.Errorf("snap_context: %w", err)
+	}
+	*s = SnapContext(t)
+
+	for _, key := range snapContextFields {
+		delete(fields, key)
+	}
+	if len(fields) > 0 {
+		s.Extras = fields
+	}
+	return nil
+}
+
+// MarshalJSON writes the typed fields and extras as one flat object
+func (s SnapContext) MarshalJSON() ([]byte, error) {
+	type typed SnapContext
+	data, err := json.Marshal(typed(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Extras) == 0 {
+		return data, nil
+	}
+
+	fields := make(map[string]json.RawMessage, len(s.Extras)+len(snapContextFields))
+	for k, v := range s.Extras {
+		fields[k] = v
+	}
+	// Typed fields win over an extra of the same name
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// Scan reads the JSONB column, upgrading older documents
+func (s *SnapContext) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = NewSnapContext()
+		return nil
+	case []byte:
+		return s.UnmarshalJSON(v)
+	case string:
+		return s.UnmarshalJSON([]byte(v))
+	default:
+		return fmt.Errorf("snap_context: cannot scan %T", src)
+	}
+}
+
+// Value validates the context before it is written
+func (s SnapContext) Value() (driver.Value, error) {
+	if s.Version == 0 {
+		s.Version = SnapContextVersion
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := s.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	// Sent as text so the driver doesn't encode it as bytea
+	return string(data), nil
+}
+
+func isDigits(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestUpgradeSnapContextV1Amount(t *testing.T) {
+	tests := []struct {
+		amount string
+		want   string
+	}{
+		{`1250`, `125000`},
+		{`12.34`, `1234`},
+		{`0.29`, `29`},
+		{`-0.29`, `-29`},
+		{`-12.34`, `-1234`},
+		{`-12.345`, `-1235`},
+		{`12.345`, `1235`},
+		{`0`, `0`},
+	}
+	for _, tt := range tests {
+		fields := map[string]json.RawMessage{"amount": json.RawMessage(tt.amount)}
+		if err := upgradeSnapContextV1(fields); err != nil {
+			t.Fatalf("upgrade amount %s: %v", tt.amount, err)
+		}
+		if got := string(fields["refund_amount_cents"]); got != tt.want {
+			t.Errorf("amount %s upgraded to %s cents, want %s", tt.amount, got, tt.want)
+		}
+		if _, ok := fields["amount"]; ok {
+			t.Errorf("amount %s was kept after upgrading", tt.amount)
+		}
+	}
+}
+
+func TestUpgradeSnapContextV1KeepsNonNumericAmount(t *testing.T) {
+	fields := map[string]json.RawMessage{"amount": json.RawMessage(`"about $1,200"`)}
+	if err := upgradeSnapContextV1(fields); err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["amount"]) != `"about $1,200"` || fields["refund_amount_cents"] != nil {
+		t.Fatalf("fields = %s, want the amount left as an extra", fields)
+	}
+}
+
+func TestSnapContextUpgrades(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc        string
+		wantExtras map[string]string
+	}{
+		{
+			name:       "v1 untyped",
+			doc:        `{"amount": -12.34, "description": "legacy", "eitc": true}`,
+			wantExtras: map[string]string{"description": `"legacy"`},
+		},
+		{
+			name:       "v1 with sensitive keys",
+			doc:        `{"ssn": "123-45-6789", "routing_number": "021000021", "account_number": "000123456789", "tin": "9", "bank_account": "x", "note": "kept", "eitc": true}`,
+			wantExtras: map[string]string{"note": `"kept"`},
+		},
+		{
+			name:       "v2 amount in cents",
+			doc:        `{"version": 2, "refund_amount_cents": 125000, "filing_type": "single", "eitc": true}`,
+			wantExtras: nil,
+		},
+		{
+			name:       "v3 current",
+			doc:        `{"version": 3, "filing_type": "single", "eitc": true, "actc": false, "scenario": 4}`,
+			wantExtras: map[string]string{"scenario": `4`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sc SnapContext
+			if err := json.Unmarshal([]byte(tt.doc), &sc); err != nil {
+				t.Fatalf("Unmarshal() = %v", err)
+			}
+			if sc.Version != SnapContextVersion {
+				t.Errorf("version = %d, want %d", sc.Version, SnapContextVersion)
+			}
+			if !sc.EITC {
+				t.Error("eitc was lost in the upgrade")
+			}
+			if len(sc.Extras) != len(tt.wantExtras) {
+				t.Errorf("extras = %s, want %v", sc.Extras, tt.wantExtras)
+			}
+			for k, want := range tt.wantExtras {
+				if got := string(sc.Extras[k]); got != want {
+					t.Errorf("extra %s = %s, want %s", k, got, want)
+				}
+			}
+			// An upgraded document can always be written back
+			if _, err := sc.Value(); err != nil {
+				t.Errorf("Value() after upgrade = %v", err)
+			}
+		})
+	}
+}
+
+func TestSnapContextRejectsUnknownVersion(t *testing.T) {
+	var sc SnapContext
+	if err := json.Unmarshal([]byte(`{"version": 0}`), &sc); err == nil {
+		t.Fatal("Unmarshal() accepted version 0")
+	}
+}
+
+func TestSnapContextValidateRejectsSensitiveExtras(t *testing.T) {
+	sc := NewSnapContext()
+	sc.Extras = map[string]json.RawMessage{"ssn": json.RawMessage(`"123-45-6789"`)}
+	if err := sc.Validate(); !errors.Is(err, ErrInvalidSnapContext) {
+		t.Fatalf("Validate() = %v, want ErrInvalidSnapContext", err)
+	}
+}
+
+func TestSnapContextRoundTrip(t *testing.T) {
+	sc := NewSnapContext()
+	sc.FilingType = FilingMarriedJoint
+	sc.State = "CA"
+	sc.RefundMethod = RefundMethodDirectDeposit
+	sc.BankLast4 = "1234"
+	sc.ACTC = true
+	sc.Extras = map[string]json.RawMessage{"description": json.RawMessage(`"demo"`)}
+
+	data, err := json.Marshal(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got SnapContext
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.FilingType != sc.FilingType || got.State != sc.State || got.BankLast4 != sc.BankLast4 || !got.ACTC ||
+		string(got.Extras["description"]) != `"demo"` || got.MaskedAccount() != "****1234" {
+		t.Fatalf("round trip = %+v, want %+v", got, sc)
+	}
+}
@@ -0,0 +1,273 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// StallStages are the stages the stall detector watches. Returns elsewhere
+// are either waiting on a fixed schedule or finished.
+var StallStages = []string{StatusAccepted, StatusReview}
+
+// EventReturnStalled is the webhook event sent when a return is marked stalled
+const EventReturnStalled = "return.stalled"
+
+// Where a stall threshold came from
+const (
+	StallSourceTypical    = "typical"
+	StallSourcePercentile = "percentile"
+)
+
+// StallPolicy decides how long a return may sit in a stage before it is
+// stalled
+type StallPolicy struct {
+	// Factor times the jurisdiction's typical days for the stage is the
+	// threshold when no learned percentile is available
+	Factor float64
+	// Percentile, when positive, learns each jurisdiction and stage's
+	// threshold from how long the returns that have left it spent there
+	Percentile float64
+	// MinSamples is how many such returns are needed before a learned
+	// percentile replaces the typical-days threshold
+	MinSamples int
+}
+
+// StalledReturn is a return the detector has flagged
+type StalledReturn struct {
+	ReturnID       string    `db:"return_id" json:"return_id"`
+	FilingID       string    `db:"filing_id" json:"filing_id"`
+	Jurisdiction   string    `db:"jurisdiction" json:"jurisdiction"`
+	TaxYear        int       `db:"tax_year" json:"tax_year"`
+	Status         string    `db:"status" json:"status"`
+	EnteredStageAt time.Time `db:"entered_stage_at" json:"entered_stage_at"`
+	// DaysInStage is counted up to when the list was read
+	DaysInStage   float64   `db:"-" json:"days_in_stage"`
+	StalledAt     time.Time `db:"stalled_at" json:"stalled_at"`
+	StalledReason string    `db:"stalled_reason" json:"stalled_reason"`
+}
+
+// StalledEvent is the payload delivered to subscribers when a return is
+// marked stalled
+type StalledEvent struct {
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	ReturnID       string    `json:"return_id"`
+	FilingID       string    `json:"filing_id"`
+	Jurisdiction   string    `json:"jurisdiction"`
+	Status         string    `json:"status"`
+	EnteredStageAt time.Time `json:"entered_stage_at"`
+	DaysInStage    float64   `json:"days_in_stage"`
+	ThresholdDays  float64   `json:"threshold_days"`
+	// ThresholdSource is typical or percentile
+	ThresholdSource string    `json:"threshold_source"`
+	Reason          string    `json:"reason"`
+	OccurredAt      time.Time `json:"occurred_at"`
+}
+
+// stallThreshold is how long returns of one jurisdiction may spend in a stage
+type stallThreshold struct {
+	days   float64
+	source string
+}
+
+// stageDuration is a learned percentile of the time spent in one stage
+type stageDuration struct {
+	Jurisdiction string  `db:"jurisdiction"`
+	Stage        string  `db:"stage"`
+	Samples      int     `db:"samples"`
+	Days         float64 `db:"days"`
+}
+
+// learnStageDurations finds, per jurisdiction and watched stage, the given
+// percentile of the days returns that have since moved on spent in it
+func learnStageDurations(ctx context.Context, db *DB, percentile float64) ([]stageDuration, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	// WITH ORDINALITY counts from one, so idx is the index of the next entry
+	durations := []stageDuration{}
+	err := db.SelectContext(ctx, &durations, `
+		SELECT r.jurisdiction, e.entry->>'stage' AS stage,
+			count(*) AS samples,
+			percentile_cont($1) WITHIN GROUP (ORDER BY extract(epoch FROM
+				((r.history->(e.idx::int))->>'timestamp')::timestamptz - (e.entry->>'timestamp')::timestamptz) / 86400)::float8 AS days
+		FROM returns r, jsonb_array_elements(r.history) WITH ORDINALITY AS e(entry, idx)
+		WHERE e.entry->>'stage' = ANY($2) AND r.history->(e.idx::int) IS NOT NULL
+		GROUP BY 1, 2`,
+		percentile, pq.StringArray(StallStages),
+	)
+	return durations, err
+}
+
+// stallCandidate is an unflagged return in a watched stage
+type stallCandidate struct {
+	ReturnID       string    `db:"return_id"`
+	FilingID       string    `db:"filing_id"`
+	Jurisdiction   string    `db:"jurisdiction"`
+	Status         string    `db:"status"`
+	EnteredStageAt time.Time `db:"entered_stage_at"`
+}
+
+// DetectStalledReturns flags every return that has been in ACCEPTED or
+// REVIEW longer than policy allows, recording why and enqueueing a
+// return.stalled webhook for each. Flags already set are left alone; a
+// transition clears them. It returns an event for each return newly flagged.
+func DetectStalledReturns(ctx context.Context, db *DB, policy StallPolicy, now time.Time) ([]StalledEvent, error) {
+	learned := map[[2]string]stageDuration{}
+	if policy.Percentile > 0 {
+		durations, err := learnStageDurations(ctx, db, policy.Percentile)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range durations {
+			learned[[2]string{d.Jurisdiction, d.Stage}] = d
+		}
+	}
+
+	candidates := []stallCandidate{}
+	queryCtx, cancel := db.withQueryTimeout(ctx)
+	err := db.SelectContext(queryCtx, &candidates, `
+		SELECT return_id, filing_id, jurisdiction, status,
+			(history->-1->>'timestamp')::timestamptz AS entered_stage_at
+		FROM returns
+		WHERE status = ANY($1) AND stalled_at IS NULL AND jsonb_array_length(history) > 0`,
+		pq.StringArray(StallStages),
+	)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	var flagged []StalledEvent
+	for _, c := range candidates {
+		event, ok := policy.stallEvent(c, learned, now)
+		if !ok {
+			continue
+		}
+		marked, err := markStalled(ctx, db, event)
+		if err != nil {
+			return flagged, err
+		}
+		if marked {
+			flagged = append(flagged, event)
+		}
+	}
+	return flagged, nil
+}
+
+// stallEvent describes c as stalled if, at now, it has been in its stage
+// longer than the threshold for its jurisdiction, using the durations learned
+// per jurisdiction and stage. It reports false if c is not stalled or its
+// jurisdiction is unknown.
+func (p StallPolicy) stallEvent(c stallCandidate, learned map[[2]string]stageDuration, now time.Time) (StalledEvent, bool) {
+	j, ok := LookupJurisdiction(c.Jurisdiction)
+	if !ok {
+		return StalledEvent{}, false
+	}
+	threshold, ok := p.threshold(j, c.Status, learned[[2]string{c.Jurisdiction, c.Status}])
+	days := now.Sub(c.EnteredStageAt).Hours() / 24
+	if !ok || days <= threshold.days {
+		return StalledEvent{}, false
+	}
+	return StalledEvent{
+		EventID:         NewULID(),
+		EventType:       EventReturnStalled,
+		ReturnID:        c.ReturnID,
+		FilingID:        c.FilingID,
+		Jurisdiction:    c.Jurisdiction,
+		Status:          c.Status,
+		EnteredStageAt:  c.EnteredStageAt,
+		DaysInStage:     math.Floor(days),
+		ThresholdDays:   math.Round(threshold.days),
+		ThresholdSource: threshold.source,
+		Reason:          stallReason(j, c.Status, days, threshold, p),
+		OccurredAt:      now,
+	}, true
+}
+
+// threshold picks the learned percentile when it has enough samples,
+// otherwise Factor times the stage's typical days. It reports false for
+// stages with no typical duration to compare against.
+func (p StallPolicy) threshold(j *Jurisdiction, stage string, learned stageDuration) (stallThreshold, bool) {
+	if p.Percentile > 0 && learned.Samples >= p.MinSamples && learned.Samples > 0 {
+		return stallThreshold{days: learned.Days, source: StallSourcePercentile}, true
+	}
+	typical := j.StageDays(stage)
+	if typical == 0 {
+		return stallThreshold{}, false
+	}
+	return stallThreshold{days: p.Factor * float64(typical), source: StallSourceTypical}, true
+}
+
+// stallReason explains a flag in terms a support agent can repeat
+func stallReason(j *Jurisdiction, stage string, days float64, t stallThreshold, p StallPolicy) string {
+	if t.source == StallSourcePercentile {
+		return fmt.Sprintf("in %s for %.0f days, longer than %.0f%% of %s returns that have left %s (%.0f days)",
+			stage, math.Floor(days), p.Percentile*100, j.Name, stage, t.days)
+	}
+	return fmt.Sprintf("in %s for %.0f days, more than %g times the usual %d days for %s returns",
+		stage, math.Floor(days), p.Factor, j.StageDays(stage), j.Name)
+}
+
+// markStalled flags the return and enqueues the webhook in one transaction.
+// It reports false if the return moved on or was flagged in the meantime.
+func markStalled(ctx context.Context, db *DB, event StalledEvent) (bool, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var returnID string
+	err = tx.GetContext(ctx, &returnID, `
+		UPDATE returns SET stalled_at=$3, stalled_reason=$4
+		WHERE return_id=$1 AND status=$2 AND stalled_at IS NULL
+		RETURNING return_id`,
+		event.ReturnID, event.Status, event.OccurredAt, event.Reason,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := enqueueWebhooks(ctx, tx, event.EventType, event.ReturnID, event); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ListStalledReturns returns flagged returns, most recently flagged first,
+// optionally only those in status
+func ListStalledReturns(ctx context.Context, db *DB, status string, limit int) ([]StalledReturn, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	stalled := []StalledReturn{}
+	err := db.SelectContext(ctx, &stalled, `
+		SELECT return_id, filing_id, jurisdiction, tax_year, status, stalled_at, stalled_reason,
+			(history->-1->>'timestamp')::timestamptz AS entered_stage_at
+		FROM returns
+		WHERE stalled_at IS NOT NULL AND ($1::text = '' OR status = $1)
+		ORDER BY stalled_at DESC
+		LIMIT $2`,
+		status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range stalled {
+		stalled[i].DaysInStage = math.Floor(now.Sub(stalled[i].EnteredStageAt).Hours() / 24)
+	}
+	return stalled, nil
+}
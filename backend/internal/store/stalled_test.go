@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"refund-demo/internal/config"
+)
+
+func TestStallPolicyThreshold(t *testing.T) {
+	federal, _ := LookupJurisdiction(JurisdictionFederal)
+	georgia, _ := LookupJurisdiction("GA")
+	learning := StallPolicy{Factor: 2, Percentile: 0.95, MinSamples: 30}
+
+	tests := []struct {
+		name    string
+		policy  StallPolicy
+		j       *Jurisdiction
+		stage   string
+		learned stageDuration
+		want    stallThreshold
+		ok      bool
+	}{
+		{"typical", StallPolicy{Factor: 2}, federal, StatusAccepted, stageDuration{}, stallThreshold{28, StallSourceTypical}, true},
+		{"fractional factor", StallPolicy{Factor: 1.5}, federal, StatusReview, stageDuration{}, stallThreshold{90, StallSourceTypical}, true},
+		{"state override", StallPolicy{Factor: 2}, georgia, StatusAccepted, stageDuration{}, stallThreshold{84, StallSourceTypical}, true},
+		{"percentile off ignores learned", StallPolicy{Factor: 2}, federal, StatusAccepted, stageDuration{Samples: 500, Days: 9}, stallThreshold{28, StallSourceTypical}, true},
+		{"too few samples", learning, federal, StatusAccepted, stageDuration{Samples: 29, Days: 9}, stallThreshold{28, StallSourceTypical}, true},
+		{"enough samples", learning, federal, StatusAccepted, stageDuration{Samples: 30, Days: 9.5}, stallThreshold{9.5, StallSourcePercentile}, true},
+		{"learned longer than typical", learning, federal, StatusReview, stageDuration{Samples: 80, Days: 150}, stallThreshold{150, StallSourcePercentile}, true},
+		{"no samples with no minimum", StallPolicy{Factor: 2, Percentile: 0.9}, federal, StatusAccepted, stageDuration{}, stallThreshold{28, StallSourceTypical}, true},
+		{"no typical days", StallPolicy{Factor: 2}, federal, StatusCompleted, stageDuration{}, stallThreshold{}, false},
+		{"stage the jurisdiction lacks", StallPolicy{Factor: 2}, georgia, StatusApproved, stageDuration{}, stallThreshold{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.policy.threshold(tt.j, tt.stage, tt.learned)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: threshold() = %+v, %v; want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestStallEvent(t *testing.T) {
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days float64) time.Time {
+		return now.Add(-time.Duration(days * 24 * float64(time.Hour)))
+	}
+	candidate := func(jurisdiction, status string, entered time.Time) stallCandidate {
+		return stallCandidate{ReturnID: "01HZDEM0001AAAAAAAAAAAAAAA", FilingID: "01HZFIL0001AAAAAAAAAAAAAAA", Jurisdiction: jurisdiction, Status: status, EnteredStageAt: entered}
+	}
+	policy := StallPolicy{Factor: 2, Percentile: 0.95, MinSamples: 30}
+	learned := map[[2]string]stageDuration{
+		{"CA", StatusAccepted}:              {Jurisdiction: "CA", Stage: StatusAccepted, Samples: 40, Days: 24.6},
+		{"NY", StatusAccepted}:              {Jurisdiction: "NY", Stage: StatusAccepted, Samples: 10, Days: 5},
+		{JurisdictionFederal, StatusReview}: {Jurisdiction: JurisdictionFederal, Stage: StatusReview, Samples: 100, Days: 150},
+	}
+
+	tests := []struct {
+		name      string
+		c         stallCandidate
+		stalled   bool
+		days      float64
+		threshold float64
+		source    string
+		reason    string
+	}{
+		{"at the threshold", candidate(JurisdictionFederal, StatusAccepted, daysAgo(28)), false, 0, 0, "", ""},
+		{
+			"just past the threshold", candidate(JurisdictionFederal, StatusAccepted, daysAgo(28.5)), true, 28, 28, StallSourceTypical,
+			"in ACCEPTED for 28 days, more than 2 times the usual 14 days for IRS returns",
+		},
+		{
+			"learned for the jurisdiction", candidate("CA", StatusAccepted, daysAgo(30)), true, 30, 25, StallSourcePercentile,
+			"in ACCEPTED for 30 days, longer than 95% of California returns that have left ACCEPTED (25 days)",
+		},
+		{"learned elsewhere only", candidate("OR", StatusAccepted, daysAgo(30)), false, 0, 0, "", ""},
+		{
+			"learned from too few samples", candidate("NY", StatusAccepted, daysAgo(43)), true, 43, 42, StallSourceTypical,
+			"in ACCEPTED for 43 days, more than 2 times the usual 21 days for New York returns",
+		},
+		{"learned threshold is longer", candidate(JurisdictionFederal, StatusReview, daysAgo(130)), false, 0, 0, "", ""},
+		{"past the learned threshold", candidate(JurisdictionFederal, StatusReview, daysAgo(151)), true, 151, 150, StallSourcePercentile, ""},
+		{"unknown jurisdiction", candidate("TX", StatusAccepted, daysAgo(400)), false, 0, 0, "", ""},
+	}
+	for _, tt := range tests {
+		event, ok := policy.stallEvent(tt.c, learned, now)
+		if ok != tt.stalled {
+			t.Errorf("%s: stallEvent() stalled = %v, want %v", tt.name, ok, tt.stalled)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if event.EventType != EventReturnStalled || event.ReturnID != tt.c.ReturnID || !event.OccurredAt.Equal(now) {
+			t.Errorf("%s: stallEvent() = %+v, want a return.stalled event for the candidate at now", tt.name, event)
+		}
+		if event.DaysInStage != tt.days || event.ThresholdDays != tt.threshold || event.ThresholdSource != tt.source {
+			t.Errorf("%s: stallEvent() = %v days against %v (%s), want %v against %v (%s)", tt.name,
+				event.DaysInStage, event.ThresholdDays, event.ThresholdSource, tt.days, tt.threshold, tt.source)
+		}
+		if tt.reason != "" && event.Reason != tt.reason {
+			t.Errorf("%s: reason = %q, want %q", tt.name, event.Reason, tt.reason)
+		}
+	}
+}
+
+// TestStalledWebhookSentOncePerStall runs the detector against a real
+// database. Set TEST_DATABASE_URL to a scratch database; it is migrated.
+func TestStalledWebhookSentOncePerStall(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	dbCfg := config.Default().Database
+	dbCfg.DSN = dsn
+	dbCfg.MigrationsPath = "file://../../migrations"
+	eta, err := NewETAEngine(config.Default())
+	if err != nil {
+		t.Fatalf("NewETAEngine() = %v", err)
+	}
+	db, _ := InitPostgres(dbCfg, false, eta)
+	defer db.Close()
+
+	sub, err := CreateWebhookSubscription(ctx, db, "http://127.0.0.1:9/hooks", "secret", []string{EventReturnStalled})
+	if err != nil {
+		t.Fatalf("CreateWebhookSubscription() = %v", err)
+	}
+	defer DeleteWebhookSubscription(ctx, db, sub.SubscriptionID)
+
+	id, err := InsertDemoReturn(ctx, db, eta)
+	if err != nil {
+		t.Fatalf("InsertDemoReturn() = %v", err)
+	}
+	// enterStage puts the return in status as of days ago
+	enterStage := func(status string, days int) {
+		_, err := db.ExecContext(ctx, `
+			UPDATE returns SET status=$2,
+				history=jsonb_build_array(jsonb_build_object('stage', $2::text, 'timestamp', now() - make_interval(days => $3)))
+			WHERE return_id=$1`,
+			id, status, days,
+		)
+		if err != nil {
+			t.Fatalf("moving return to %s: %v", status, err)
+		}
+	}
+	detect := func() bool {
+		t.Helper()
+		flagged, err := DetectStalledReturns(ctx, db, StallPolicy{Factor: 2}, time.Now())
+		if err != nil {
+			t.Fatalf("DetectStalledReturns() = %v", err)
+		}
+		for _, e := range flagged {
+			if e.ReturnID == id {
+				return true
+			}
+		}
+		return false
+	}
+	deliveries := func() int {
+		t.Helper()
+		var n int
+		err := db.GetContext(ctx, &n, `
+			SELECT count(*) FROM webhook_deliveries
+			WHERE subscription_id=$1 AND return_id=$2 AND event_type=$3`,
+			sub.SubscriptionID, id, EventReturnStalled,
+		)
+		if err != nil {
+			t.Fatalf("counting deliveries: %v", err)
+		}
+		return n
+	}
+
+	enterStage(StatusAccepted, 30)
+	if !detect() || deliveries() != 1 {
+		t.Fatalf("first detection: want the return flagged with one delivery, got %d", deliveries())
+	}
+	if detect() || deliveries() != 1 {
+		t.Fatalf("second detection: want no new flag or delivery, got %d deliveries", deliveries())
+	}
+
+	// Another replica flagging the same stall concurrently loses the race
+	event := StalledEvent{EventType: EventReturnStalled, ReturnID: id, Status: StatusAccepted, OccurredAt: time.Now()}
+	if marked, err := markStalled(ctx, db, event); err != nil || marked {
+		t.Fatalf("markStalled() on a flagged return = %v, %v; want false", marked, err)
+	}
+	if n := deliveries(); n != 1 {
+		t.Fatalf("deliveries after a lost race = %d, want 1", n)
+	}
+
+	// A transition clears the flag, so a new stall in the next stage is sent
+	if _, err := TransitionReturn(ctx, db, eta, id, StatusReview, 0); err != nil {
+		t.Fatalf("TransitionReturn() = %v", err)
+	}
+	enterStage(StatusReview, 130)
+	if !detect() || deliveries() != 2 {
+		t.Fatalf("stall in the next stage: want the return flagged with a second delivery, got %d", deliveries())
+	}
+}
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "PENDING"
+	DeliveryDelivered = "DELIVERED"
+	DeliveryDead      = "DEAD"
+)
+
+// WebhookSubscription is a downstream endpoint notified on status transitions
+type WebhookSubscription struct {
+	SubscriptionID string         `db:"subscription_id" json:"subscription_id"`
+	URL            string         `db:"url" json:"url"`
+	Secret         string         `db:"secret" json:"-"`
+	Events         pq.StringArray `db:"events" json:"events"`
+	Active         bool           `db:"active" json:"active"`
+	CreatedAt      time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// WebhookDelivery is a single outbox row for one subscription and one event
+type WebhookDelivery struct {
+	DeliveryID     string          `db:"delivery_id" json:"delivery_id"`
+	SubscriptionID string          `db:"subscription_id" json:"subscription_id"`
+	ReturnID       string          `db:"return_id" json:"return_id"`
+	EventType      string          `db:"event_type" json:"event_type"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	LastError      *string         `db:"last_error" json:"last_error"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at"`
+
+	// Joined from webhook_subscriptions when claimed for dispatch
+	URL    string `db:"url" json:"-"`
+	Secret string `db:"secret" json:"-"`
+}
+
+// StatusEvent is the payload delivered to subscribers when a return changes status
+type StatusEvent struct {
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	ReturnID       string    `json:"return_id"`
+	FilingID       string    `json:"filing_id"`
+	Jurisdiction   string    `json:"jurisdiction"`
+	PreviousStatus string    `json:"previous_status"`
+	Status         string    `json:"status"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// StatusEventType maps a refund status to its webhook event type (e.g., SENT -> return.sent)
+func StatusEventType(status string) string {
+	return "return." + strings.ToLower(status)
+}
+
+// WebhookEventAll subscribes to every event type
+const WebhookEventAll = "*"
+
+// IsWebhookEventType reports whether subscribers can receive eventType: a
+// status change, return.stalled or WebhookEventAll
+func IsWebhookEventType(eventType string) bool {
+	if eventType == WebhookEventAll || eventType == EventReturnStalled {
+		return true
+	}
+	for _, status := range Statuses {
+		if eventType == StatusEventType(status) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookSubscription registers a new subscriber
+func CreateWebhookSubscription(ctx context.Context, db *DB, url, secret string, events []string) (*WebhookSubscription, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	if events == nil {
+		events = []string{}
+	}
+	sub := WebhookSubscription{}
+	err := db.GetContext(ctx, &sub, `
+		INSERT INTO webhook_subscriptions (subscription_id, url, secret, events)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *`,
+		NewULID(), url, secret, pq.StringArray(events),
+	)
+	return &sub, err
+}
+
+// ListWebhookSubscriptions returns all subscriptions, newest first
+func ListWebhookSubscriptions(ctx context.Context, db *DB) ([]WebhookSubscription, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	subs := []WebhookSubscription{}
+	err := db.SelectContext(ctx, &subs, "SELECT * FROM webhook_subscriptions ORDER BY created_at DESC")
+	return subs, err
+}
+
+// DeleteWebhookSubscription removes a subscription and its pending deliveries
+func DeleteWebhookSubscription(ctx context.Context, db *DB, id string) (bool, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE subscription_id=$1", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ListWebhookDeliveries returns the most recent deliveries, optionally filtered by status
+func ListWebhookDeliveries(ctx context.Context, db *DB, status string, limit int) ([]WebhookDelivery, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	deliveries := []WebhookDelivery{}
+	err := db.SelectContext(ctx, &deliveries, `
+		SELECT d.*, s.url, '' AS secret
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.subscription_id = d.subscription_id
+		WHERE ($1::text = '' OR d.status = $1)
+		ORDER BY d.created_at DESC
+		LIMIT $2`,
+		status, limit,
+	)
+	return deliveries, err
+}
+
+// enqueueStatusWebhooks writes one outbox row per matching active subscription.
+// It must run in the same transaction as the status change it describes.
+func enqueueStatusWebhooks(ctx context.Context, tx *sqlx.Tx, event StatusEvent) error {
+	return enqueueWebhooks(ctx, tx, event.EventType, event.ReturnID, event)
+}
+
+// enqueueWebhooks writes event as one outbox row per active subscription to
+// eventType, in the transaction of the change it describes
+func enqueueWebhooks(ctx context.Context, tx *sqlx.Tx, eventType, returnID string, event interface{}) error {
+	var subIDs []string
+	err := tx.SelectContext(ctx, &subIDs, `
+		SELECT subscription_id FROM webhook_subscriptions
+		WHERE active AND (cardinality(events) = 0 OR $1 = ANY(events) OR '*' = ANY(events))`,
+		eventType,
+	)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, subID := range subIDs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO webhook_deliveries (delivery_id, subscription_id, return_id, event_type, payload)
+			VALUES ($1, $2, $3, $4, $5)`,
+			NewULID(), subID, returnID, eventType, payload,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveries leases up to limit due deliveries for dispatch.
+// Claimed rows have next_attempt_at pushed out by lease so that other
+// dispatcher replicas skip them while the HTTP call is in flight. Deliveries
+// for inactive subscriptions stay pending and are not sent.
+func ClaimWebhookDeliveries(ctx context.Context, db *DB, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	deliveries := []WebhookDelivery{}
+	err := db.SelectContext(ctx, &deliveries, `
+		WITH due AS (
+			SELECT d.delivery_id FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.subscription_id = d.subscription_id
+			WHERE d.status = 'PENDING' AND d.next_attempt_at <= now() AND s.active
+			ORDER BY d.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + make_interval(secs => $2)
+		FROM due, webhook_subscriptions s
+		WHERE d.delivery_id = due.delivery_id AND s.subscription_id = d.subscription_id
+		RETURNING d.*, s.url, s.secret`,
+		limit, lease.Seconds(),
+	)
+	return deliveries, err
+}
+
+// MarkWebhookDelivered records a successful delivery
+func MarkWebhookDelivered(ctx context.Context, db *DB, id string) error {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'DELIVERED', attempts = attempts + 1, delivered_at = now(), last_error = NULL
+		WHERE delivery_id = $1`,
+		id,
+	)
+	return err
+}
+
+// MarkWebhookFailed records a failed attempt and either schedules a retry
+// at nextAttempt or, when dead is true, moves the delivery to the dead-letter state
+func MarkWebhookFailed(ctx context.Context, db *DB, id string, errMsg string, nextAttempt time.Time, dead bool) error {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	status := DeliveryPending
+	if dead {
+		status = DeliveryDead
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, last_error = $3, next_attempt_at = $4
+		WHERE delivery_id = $1`,
+		id, status, errMsg, nextAttempt,
+	)
+	return err
+}
+
+// RetryWebhookDelivery moves a dead delivery back to pending for immediate redelivery
+func RetryWebhookDelivery(ctx context.Context, db *DB, id string) (bool, error) {
+	ctx, cancel := db.withQueryTimeout(ctx)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'PENDING', attempts = 0, next_attempt_at = now()
+		WHERE delivery_id = $1 AND status = 'DEAD'`,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
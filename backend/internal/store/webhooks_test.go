@@ -0,0 +1,26 @@
+package store
+
+import "testing"
+
+func TestIsWebhookEventType(t *testing.T) {
+	tests := []struct {
+		eventType string
+		want      bool
+	}{
+		{"return.filed", true},
+		{"return.accepted", true},
+		{"return.review", true},
+		{"return.completed", true},
+		{"return.stalled", true},
+		{"*", true},
+		{"return.SENT", false},
+		{"return.refunded", false},
+		{"status.sent", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsWebhookEventType(tt.eventType); got != tt.want {
+			t.Errorf("IsWebhookEventType(%q) = %v, want %v", tt.eventType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestMiddlewareRecordsRouteTemplate(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/v1/status/:return_id", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/v1/status/RET-SECRET-123", nil))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if got, want := span.Name(), "GET /v1/status/:return_id"; got != want {
+		t.Errorf("span name = %q, want %q", got, want)
+	}
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	for _, key := range []attribute.Key{semconv.URLPathKey, semconv.HTTPRouteKey} {
+		if got := attrs[key].AsString(); got != "/v1/status/:return_id" {
+			t.Errorf("%s = %q, want the route template", key, got)
+		}
+	}
+	for _, kv := range span.Attributes() {
+		if kv.Value.Type() == attribute.STRING && kv.Value.AsString() == "/v1/status/RET-SECRET-123" {
+			t.Errorf("%s records the raw path", kv.Key)
+		}
+	}
+}
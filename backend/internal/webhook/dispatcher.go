@@ -0,0 +1,194 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"refund-demo/internal/store"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Outbox is where the dispatcher claims deliveries and records how they went
+type Outbox interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]store.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, deliveryID string) error
+	MarkFailed(ctx context.Context, deliveryID, errMsg string, nextAttempt time.Time, dead bool) error
+}
+
+// dbOutbox is the webhook_deliveries table
+type dbOutbox struct {
+	db *store.DB
+}
+
+func (o dbOutbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]store.WebhookDelivery, error) {
+	return store.ClaimWebhookDeliveries(ctx, o.db, limit, lease)
+}
+
+func (o dbOutbox) MarkDelivered(ctx context.Context, deliveryID string) error {
+	return store.MarkWebhookDelivered(ctx, o.db, deliveryID)
+}
+
+func (o dbOutbox) MarkFailed(ctx context.Context, deliveryID, errMsg string, nextAttempt time.Time, dead bool) error {
+	return store.MarkWebhookFailed(ctx, o.db, deliveryID, errMsg, nextAttempt, dead)
+}
+
+// Dispatcher polls the webhook outbox and delivers signed payloads to subscribers.
+// Failed deliveries are retried with exponential backoff until MaxAttempts is
+// reached, after which they are moved to the DEAD state.
+type Dispatcher struct {
+	outbox Outbox
+	client *http.Client
+
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher for the webhook_deliveries table with
+// default settings
+func NewDispatcher(db *store.DB) *Dispatcher {
+	return NewOutboxDispatcher(dbOutbox{db})
+}
+
+// NewOutboxDispatcher creates a dispatcher for outbox with default settings
+func NewOutboxDispatcher(outbox Outbox) *Dispatcher {
+	return &Dispatcher{
+		outbox: outbox,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// A redirect would re-post the signed payload to a URL nobody
+			// registered; the 3xx is treated as a failed attempt instead
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		PollInterval: 2 * time.Second,
+		BatchSize:    20,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   time.Hour,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start launches the polling loop in the background
+func (d *Dispatcher) Start() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(d.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				d.RunOnce()
+			}
+		}
+	}()
+	log.Info().Dur("poll_interval", d.PollInterval).Msg("webhook dispatcher started")
+}
+
+// Stop halts polling and waits for the in-flight batch to finish
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+	log.Info().Msg("webhook dispatcher stopped")
+}
+
+// RunOnce claims and delivers a single batch of due deliveries
+func (d *Dispatcher) RunOnce() {
+	// Lease long enough to cover the HTTP timeout so other replicas don't double-send
+	lease := d.client.Timeout + 30*time.Second
+	deliveries, err := d.outbox.Claim(context.Background(), d.BatchSize, lease)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to claim webhook deliveries")
+		return
+	}
+
+	for _, delivery := range deliveries {
+		d.deliver(delivery)
+	}
+}
+
+func (d *Dispatcher) deliver(delivery store.WebhookDelivery) {
+	logger := log.With().
+		Str("delivery_id", delivery.DeliveryID).
+		Str("subscription_id", delivery.SubscriptionID).
+		Str("event_type", delivery.EventType).
+		Int("attempt", delivery.Attempts+1).
+		Logger()
+
+	sendErr := d.send(delivery)
+	if sendErr == nil {
+		if err := d.outbox.MarkDelivered(context.Background(), delivery.DeliveryID); err != nil {
+			logger.Error().Err(err).Msg("failed to mark webhook delivered")
+			return
+		}
+		logger.Info().Msg("webhook delivered")
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	dead := attempts >= d.MaxAttempts
+	next := time.Now().Add(d.backoff(attempts))
+	if err := d.outbox.MarkFailed(context.Background(), delivery.DeliveryID, sendErr.Error(), next, dead); err != nil {
+		logger.Error().Err(err).Msg("failed to record webhook failure")
+		return
+	}
+
+	if dead {
+		logger.Error().Err(sendErr).Msg("webhook delivery moved to dead-letter")
+	} else {
+		logger.Warn().Err(sendErr).Time("next_attempt_at", next).Msg("webhook delivery failed, will retry")
+	}
+}
+
+func (d *Dispatcher) send(delivery store.WebhookDelivery) error {
+	now := time.Now()
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "refund-demo-webhooks/1.0")
+	req.Header.Set(HeaderDeliveryID, delivery.DeliveryID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, fmt.Sprintf("%d", now.Unix()))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, now, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// backoff returns BaseBackoff * 2^(attempts-1), capped at MaxBackoff
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= d.MaxBackoff {
+			return d.MaxBackoff
+		}
+	}
+	return wait
+}
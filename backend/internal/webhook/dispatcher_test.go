@@ -0,0 +1,264 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"refund-demo/internal/store"
+)
+
+// memoryOutbox keeps deliveries in memory. Every pending delivery is due, so
+// each RunOnce attempts them all; the retry time chosen is recorded instead.
+type memoryOutbox struct {
+	mu         sync.Mutex
+	deliveries map[string]*store.WebhookDelivery
+	retries    []time.Duration
+}
+
+func newMemoryOutbox(deliveries ...store.WebhookDelivery) *memoryOutbox {
+	o := &memoryOutbox{deliveries: map[string]*store.WebhookDelivery{}}
+	for i := range deliveries {
+		d := deliveries[i]
+		d.Status = store.DeliveryPending
+		o.deliveries[d.DeliveryID] = &d
+	}
+	return o
+}
+
+func (o *memoryOutbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]store.WebhookDelivery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var claimed []store.WebhookDelivery
+	for _, d := range o.deliveries {
+		if d.Status == store.DeliveryPending && len(claimed) < limit {
+			claimed = append(claimed, *d)
+		}
+	}
+	return claimed, nil
+}
+
+func (o *memoryOutbox) MarkDelivered(ctx context.Context, deliveryID string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	d := o.deliveries[deliveryID]
+	d.Status = store.DeliveryDelivered
+	d.Attempts++
+	return nil
+}
+
+func (o *memoryOutbox) MarkFailed(ctx context.Context, deliveryID, errMsg string, nextAttempt time.Time, dead bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	d := o.deliveries[deliveryID]
+	d.Attempts++
+	d.LastError = &errMsg
+	d.NextAttemptAt = nextAttempt
+	if dead {
+		d.Status = store.DeliveryDead
+	}
+	o.retries = append(o.retries, time.Until(nextAttempt))
+	return nil
+}
+
+func (o *memoryOutbox) get(id string) store.WebhookDelivery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return *o.deliveries[id]
+}
+
+// receiver is a subscriber that verifies every request's signature and
+// responds 500 to the first failures requests
+type receiver struct {
+	t        *testing.T
+	secret   string
+	failures int
+
+	mu       sync.Mutex
+	requests int
+	events   []string
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	if err := Verify(r.secret, req.Header.Get(HeaderSignature), req.Header.Get(HeaderTimestamp), body, DefaultTolerance); err != nil {
+		r.t.Errorf("receiver rejected delivery %s: %v", req.Header.Get(HeaderDeliveryID), err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if r.requests <= r.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	r.events = append(r.events, req.Header.Get(HeaderEvent))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTestDispatcher(outbox Outbox) *Dispatcher {
+	d := NewOutboxDispatcher(outbox)
+	d.BaseBackoff = time.Minute
+	d.MaxBackoff = 10 * time.Minute
+	d.MaxAttempts = 4
+	return d
+}
+
+func testDelivery(url, secret string) store.WebhookDelivery {
+	return store.WebhookDelivery{
+		DeliveryID:     "01HZDEL0001AAAAAAAAAAAAAAA",
+		SubscriptionID: "01HZSUB0001AAAAAAAAAAAAAAA",
+		ReturnID:       "01HZDEM0001AAAAAAAAAAAAAAA",
+		EventType:      "return.sent",
+		Payload:        []byte(`{"event_type":"return.sent","status":"SENT"}`),
+		URL:            url,
+		Secret:         secret,
+	}
+}
+
+func TestDispatcherDeliversSignedPayload(t *testing.T) {
+	recv := &receiver{t: t, secret: "dev-secret"}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	outbox := newMemoryOutbox(testDelivery(server.URL, "dev-secret"))
+	newTestDispatcher(outbox).RunOnce()
+
+	got := outbox.get("01HZDEL0001AAAAAAAAAAAAAAA")
+	if got.Status != store.DeliveryDelivered || got.Attempts != 1 {
+		t.Fatalf("delivery = %s after %d attempts, want DELIVERED after 1", got.Status, got.Attempts)
+	}
+	if len(recv.events) != 1 || recv.events[0] != "return.sent" {
+		t.Fatalf("receiver got events %v, want [return.sent]", recv.events)
+	}
+}
+
+func TestDispatcherRejectedSignature(t *testing.T) {
+	var status int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if err := Verify("receiver-secret", req.Header.Get(HeaderSignature), req.Header.Get(HeaderTimestamp), body, DefaultTolerance); err != nil {
+			status = http.StatusUnauthorized
+		} else {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	outbox := newMemoryOutbox(testDelivery(server.URL, "stale-secret"))
+	newTestDispatcher(outbox).RunOnce()
+
+	if status != http.StatusUnauthorized {
+		t.Fatalf("receiver responded %d to a payload signed with the wrong secret, want 401", status)
+	}
+	if got := outbox.get("01HZDEL0001AAAAAAAAAAAAAAA"); got.Status != store.DeliveryPending || got.LastError == nil {
+		t.Fatalf("delivery = %s with last_error %v, want PENDING with the error recorded", got.Status, got.LastError)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	recv := &receiver{t: t, secret: "dev-secret", failures: 2}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	outbox := newMemoryOutbox(testDelivery(server.URL, "dev-secret"))
+	d := newTestDispatcher(outbox)
+	for i := 0; i < 3; i++ {
+		d.RunOnce()
+	}
+
+	got := outbox.get("01HZDEL0001AAAAAAAAAAAAAAA")
+	if got.Status != store.DeliveryDelivered || got.Attempts != 3 {
+		t.Fatalf("delivery = %s after %d attempts, want DELIVERED after 3", got.Status, got.Attempts)
+	}
+	want := []time.Duration{time.Minute, 2 * time.Minute}
+	if len(outbox.retries) != len(want) {
+		t.Fatalf("scheduled %d retries, want %d", len(outbox.retries), len(want))
+	}
+	for i, retry := range outbox.retries {
+		if retry < want[i]-time.Second || retry > want[i] {
+			t.Errorf("retry %d scheduled in %v, want %v", i+1, retry, want[i])
+		}
+	}
+}
+
+func TestDispatcherDeadLettersAfterMaxAttempts(t *testing.T) {
+	recv := &receiver{t: t, secret: "dev-secret", failures: 100}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	outbox := newMemoryOutbox(testDelivery(server.URL, "dev-secret"))
+	d := newTestDispatcher(outbox)
+	for i := 0; i < d.MaxAttempts+2; i++ {
+		d.RunOnce()
+	}
+
+	got := outbox.get("01HZDEL0001AAAAAAAAAAAAAAA")
+	if got.Status != store.DeliveryDead || got.Attempts != d.MaxAttempts {
+		t.Fatalf("delivery = %s after %d attempts, want DEAD after %d", got.Status, got.Attempts, d.MaxAttempts)
+	}
+	if recv.requests != d.MaxAttempts {
+		t.Fatalf("receiver got %d requests, want %d; dead deliveries must not be retried", recv.requests, d.MaxAttempts)
+	}
+}
+
+func TestDispatcherUnreachableSubscriber(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	outbox := newMemoryOutbox(testDelivery(url, "dev-secret"))
+	newTestDispatcher(outbox).RunOnce()
+
+	if got := outbox.get("01HZDEL0001AAAAAAAAAAAAAAA"); got.Status != store.DeliveryPending || got.Attempts != 1 {
+		t.Fatalf("delivery = %s after %d attempts, want PENDING after 1", got.Status, got.Attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{BaseBackoff: 30 * time.Second, MaxBackoff: time.Hour}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDispatcherDoesNotFollowRedirects(t *testing.T) {
+	recv := &receiver{t: t, secret: "dev-secret"}
+	elsewhere := httptest.NewServer(recv)
+	defer elsewhere.Close()
+	redirector := httptest.NewServer(http.RedirectHandler(elsewhere.URL, http.StatusTemporaryRedirect))
+	defer redirector.Close()
+
+	outbox := newMemoryOutbox(testDelivery(redirector.URL, "dev-secret"))
+	newTestDispatcher(outbox).RunOnce()
+
+	if recv.requests != 0 {
+		t.Fatalf("redirect target got %d requests, want none", recv.requests)
+	}
+	if got := outbox.get("01HZDEL0001AAAAAAAAAAAAAAA"); got.Status != store.DeliveryPending || got.LastError == nil {
+		t.Fatalf("delivery = %s with last_error %v, want PENDING with the redirect recorded as a failure", got.Status, got.LastError)
+	}
+}
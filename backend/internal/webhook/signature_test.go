@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"event_type":"return.sent"}`)
+	now := time.Now()
+	stamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("secret", now, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		want      error
+	}{
+		{"valid", "secret", signature, stamp, body, nil},
+		{"padded signature", "secret", " " + signature + " ", stamp, body, nil},
+		{"wrong secret", "other", signature, stamp, body, ErrInvalidSignature},
+		{"tampered body", "secret", signature, stamp, []byte(`{"event_type":"return.completed"}`), ErrInvalidSignature},
+		{"timestamp not signed", "secret", signature, strconv.FormatInt(now.Unix()+1, 10), body, ErrInvalidSignature},
+		{"stale timestamp", "secret", Sign("secret", now.Add(-time.Hour), body), strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), body, ErrStaleTimestamp},
+		{"future timestamp", "secret", Sign("secret", now.Add(time.Hour), body), strconv.FormatInt(now.Add(time.Hour).Unix(), 10), body, ErrStaleTimestamp},
+		{"malformed timestamp", "secret", signature, "yesterday", body, ErrInvalidSignature},
+		{"missing signature", "secret", "", stamp, body, ErrMissingSignature},
+		{"missing timestamp", "secret", signature, "", body, ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.signature, tt.timestamp, tt.body, DefaultTolerance)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutTolerance(t *testing.T) {
+	body := []byte(`{}`)
+	old := time.Now().Add(-24 * time.Hour)
+	err := Verify("secret", Sign("secret", old, body), strconv.FormatInt(old.Unix(), 10), body, 0)
+	if err != nil {
+		t.Fatalf("Verify() with no tolerance = %v, want nil", err)
+	}
+}
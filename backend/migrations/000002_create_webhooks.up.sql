@@ -0,0 +1,43 @@
+-- Create webhook subscriptions table for downstream status notifications
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+  subscription_id TEXT PRIMARY KEY,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  events TEXT[] NOT NULL DEFAULT '{}',
+  active BOOLEAN NOT NULL DEFAULT true,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Create webhook outbox; rows are written in the same transaction as the status change
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  delivery_id TEXT PRIMARY KEY,
+  subscription_id TEXT NOT NULL REFERENCES webhook_subscriptions(subscription_id) ON DELETE CASCADE,
+  return_id TEXT NOT NULL REFERENCES returns(return_id) ON DELETE CASCADE,
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  status TEXT NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'DELIVERED', 'DEAD')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  delivered_at TIMESTAMPTZ
+);
+
+-- Dispatcher polls pending deliveries that are due
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending
+  ON webhook_deliveries(next_attempt_at)
+  WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id ON webhook_deliveries(subscription_id);
+
+-- Reuse the updated_at trigger function from 000001
+CREATE TRIGGER update_webhook_subscriptions_updated_at BEFORE UPDATE ON webhook_subscriptions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Add comments for documentation
+COMMENT ON TABLE webhook_subscriptions IS 'Downstream subscribers notified on refund status transitions';
+COMMENT ON COLUMN webhook_subscriptions.secret IS 'Shared secret used to HMAC-sign webhook payloads';
+COMMENT ON COLUMN webhook_subscriptions.events IS 'Event types to deliver (e.g., return.sent); empty means all';
+COMMENT ON TABLE webhook_deliveries IS 'Webhook outbox with retry and dead-letter state';
+COMMENT ON COLUMN webhook_deliveries.status IS 'Delivery state: PENDING, DELIVERED, or DEAD (retries exhausted)';
+COMMENT ON COLUMN webhook_deliveries.next_attempt_at IS 'Earliest time the dispatcher may attempt (or re-attempt) delivery';
@@ -0,0 +1,6 @@
+-- Drop triggers first
+DROP TRIGGER IF EXISTS notify_returns_status_update ON returns;
+DROP TRIGGER IF EXISTS notify_returns_status_insert ON returns;
+
+-- Drop the trigger function
+DROP FUNCTION IF EXISTS notify_return_status();
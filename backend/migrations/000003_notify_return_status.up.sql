@@ -0,0 +1,26 @@
+-- Publish status transitions on the return_status channel so every replica
+-- listening via LISTEN can fan changes out to its SSE subscribers
+CREATE OR REPLACE FUNCTION notify_return_status()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify(
+        'return_status',
+        json_build_object(
+            'return_id', NEW.return_id,
+            'status', NEW.status
+        )::text
+    );
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+-- Fire on insert and on updates that actually change the status
+CREATE TRIGGER notify_returns_status_insert AFTER INSERT ON returns
+    FOR EACH ROW EXECUTE FUNCTION notify_return_status();
+
+CREATE TRIGGER notify_returns_status_update AFTER UPDATE ON returns
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status)
+    EXECUTE FUNCTION notify_return_status();
+
+COMMENT ON FUNCTION notify_return_status() IS 'Sends NOTIFY return_status with {return_id, status} on status transitions';
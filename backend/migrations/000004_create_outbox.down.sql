@@ -0,0 +1,12 @@
+-- Drop trigger first
+DROP TRIGGER IF EXISTS record_returns_change ON returns;
+
+-- Drop the trigger function
+DROP FUNCTION IF EXISTS record_return_change();
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_outbox_aggregate_id;
+DROP INDEX IF EXISTS idx_outbox_unpublished;
+
+-- Drop the table
+DROP TABLE IF EXISTS outbox;
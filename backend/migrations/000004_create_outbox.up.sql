@@ -0,0 +1,49 @@
+-- Create outbox for change-data events on returns. Rows are written by triggers
+-- in the same transaction as the change, so an event exists iff the change committed.
+CREATE TABLE IF NOT EXISTS outbox (
+  id BIGSERIAL PRIMARY KEY,
+  event_id UUID NOT NULL DEFAULT gen_random_uuid(),
+  aggregate_id TEXT NOT NULL,
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  published_at TIMESTAMPTZ
+);
+
+-- Relay reads unpublished events in id order
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox(id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_aggregate_id ON outbox(aggregate_id, id);
+
+-- Trigger function recording inserts, updates and deletes of returns
+CREATE OR REPLACE FUNCTION record_return_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO outbox (aggregate_id, event_type, payload)
+        VALUES (NEW.return_id, 'return.created', jsonb_build_object('return', to_jsonb(NEW)));
+        RETURN NEW;
+    ELSIF TG_OP = 'UPDATE' THEN
+        INSERT INTO outbox (aggregate_id, event_type, payload)
+        VALUES (NEW.return_id, 'return.updated', jsonb_build_object(
+            'return', to_jsonb(NEW),
+            'previous_status', OLD.status
+        ));
+        RETURN NEW;
+    ELSE
+        INSERT INTO outbox (aggregate_id, event_type, payload)
+        VALUES (OLD.return_id, 'return.deleted', jsonb_build_object('return', to_jsonb(OLD)));
+        RETURN OLD;
+    END IF;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_returns_change AFTER INSERT OR UPDATE OR DELETE ON returns
+    FOR EACH ROW EXECUTE FUNCTION record_return_change();
+
+-- Add comments for documentation
+COMMENT ON TABLE outbox IS 'Transactional outbox of change-data events, published by the relay';
+COMMENT ON COLUMN outbox.id IS 'Monotonic sequence; defines publish order per aggregate';
+COMMENT ON COLUMN outbox.event_id IS 'Stable event identifier consumers can use for de-duplication';
+COMMENT ON COLUMN outbox.aggregate_id IS 'return_id the event belongs to';
+COMMENT ON COLUMN outbox.event_type IS 'return.created, return.updated or return.deleted';
+COMMENT ON COLUMN outbox.published_at IS 'When the relay successfully published the event; NULL while pending';
@@ -0,0 +1,8 @@
+-- Drop trigger first
+DROP TRIGGER IF EXISTS bump_returns_version ON returns;
+
+-- Drop the trigger function
+DROP FUNCTION IF EXISTS bump_version_column();
+
+-- Drop the column
+ALTER TABLE returns DROP COLUMN IF EXISTS version;
@@ -0,0 +1,17 @@
+-- Add a version column for optimistic concurrency control
+ALTER TABLE returns ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- Bump the version on every update so conditional writes detect stale reads,
+-- even for updates that don't set version explicitly
+CREATE OR REPLACE FUNCTION bump_version_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER bump_returns_version BEFORE UPDATE ON returns
+    FOR EACH ROW EXECUTE FUNCTION bump_version_column();
+
+COMMENT ON COLUMN returns.version IS 'Row version incremented on every update; used for optimistic concurrency and ETags';
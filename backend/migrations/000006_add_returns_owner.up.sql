@@ -0,0 +1,6 @@
+-- Add owner for per-return authorization; matched against the caller's token claim
+ALTER TABLE returns ADD COLUMN IF NOT EXISTS owner_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_returns_owner_id ON returns(owner_id);
+
+COMMENT ON COLUMN returns.owner_id IS 'Owner/tenant identifier; only principals with a matching claim may read the return';
@@ -0,0 +1,7 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_admin_audit_log_key_id;
+DROP INDEX IF EXISTS idx_admin_audit_log_occurred_at;
+
+-- Drop tables (audit log references api keys)
+DROP TABLE IF EXISTS admin_audit_log;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,34 @@
+-- Create API keys for the /internal admin routes. Only a SHA-256 hash of each
+-- key is stored; the plaintext is shown once when the key is created.
+CREATE TABLE IF NOT EXISTS api_keys (
+  key_id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  key_hash TEXT NOT NULL UNIQUE,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_used_at TIMESTAMPTZ,
+  revoked_at TIMESTAMPTZ
+);
+
+-- Create audit log of every request to the admin routes, including rejected ones
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+  audit_id TEXT PRIMARY KEY,
+  key_id TEXT REFERENCES api_keys(key_id) ON DELETE SET NULL,
+  action TEXT NOT NULL,
+  method TEXT NOT NULL,
+  path TEXT NOT NULL,
+  status_code INTEGER NOT NULL,
+  remote_ip TEXT,
+  occurred_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_occurred_at ON admin_audit_log(occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_key_id ON admin_audit_log(key_id);
+
+-- Add comments for documentation
+COMMENT ON TABLE api_keys IS 'Hashed API keys with scopes for /internal admin routes';
+COMMENT ON COLUMN api_keys.key_hash IS 'Hex SHA-256 of the full API key';
+COMMENT ON COLUMN api_keys.scopes IS 'Granted scopes (e.g., returns:write, webhooks:read, admin)';
+COMMENT ON TABLE admin_audit_log IS 'Audit trail of admin actions';
+COMMENT ON COLUMN admin_audit_log.action IS 'Route pattern that was invoked (e.g., POST /internal/scrape)';
+COMMENT ON COLUMN admin_audit_log.key_id IS 'API key used; NULL when authentication failed';
@@ -0,0 +1,5 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_rate_limit_buckets_updated_at;
+
+-- Drop the table
+DROP TABLE IF EXISTS rate_limit_buckets;
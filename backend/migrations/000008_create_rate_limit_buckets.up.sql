@@ -0,0 +1,13 @@
+-- Token buckets shared by all replicas when RATE_LIMIT_STORE=postgres.
+-- UNLOGGED: bucket state is cheap to lose on crash and written on every request.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+  bucket_key TEXT PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
+
+COMMENT ON TABLE rate_limit_buckets IS 'Token bucket state for API rate limiting';
+COMMENT ON COLUMN rate_limit_buckets.bucket_key IS 'Route policy plus key kind and value, e.g. explain:ip:10.0.0.1';
+COMMENT ON COLUMN rate_limit_buckets.tokens IS 'Tokens remaining as of updated_at';
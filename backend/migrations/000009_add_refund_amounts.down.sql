@@ -0,0 +1,14 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_adjustments_return_id;
+
+-- Drop the table
+DROP TABLE IF EXISTS adjustments;
+
+-- Put amounts back into snap_context as version 2 documents
+UPDATE returns SET
+  snap_context = (snap_context - 'version') || jsonb_build_object('version', 2, 'refund_amount_cents', refund_amount_cents)
+WHERE refund_amount_cents IS NOT NULL;
+
+-- Drop the columns
+ALTER TABLE returns DROP COLUMN IF EXISTS currency;
+ALTER TABLE returns DROP COLUMN IF EXISTS refund_amount_cents;
@@ -0,0 +1,33 @@
+-- First-class refund amount in integer cents, replacing the amount kept in snap_context
+ALTER TABLE returns ADD COLUMN IF NOT EXISTS refund_amount_cents BIGINT CHECK (refund_amount_cents >= 0);
+ALTER TABLE returns ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$');
+
+-- Move existing amounts out of snap_context: version 2 stored cents, earlier
+-- documents stored whole dollars under "amount"
+UPDATE returns SET
+  refund_amount_cents = CASE
+    WHEN snap_context ? 'refund_amount_cents' THEN (snap_context->>'refund_amount_cents')::bigint
+    WHEN jsonb_typeof(snap_context->'amount') = 'number' THEN round((snap_context->>'amount')::numeric * 100)::bigint
+  END,
+  snap_context = (snap_context - 'refund_amount_cents' - 'amount') || '{"version": 3}'::jsonb
+WHERE snap_context ? 'refund_amount_cents' OR jsonb_typeof(snap_context->'amount') = 'number';
+
+-- Line items that change what the taxpayer receives: offsets, interest,
+-- corrections and partial payments
+CREATE TABLE IF NOT EXISTS adjustments (
+  adjustment_id TEXT PRIMARY KEY,
+  return_id TEXT NOT NULL REFERENCES returns(return_id) ON DELETE CASCADE,
+  kind TEXT NOT NULL CHECK (kind IN ('offset', 'interest', 'correction', 'partial_payment')),
+  amount_cents BIGINT NOT NULL,
+  currency CHAR(3) NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$'),
+  description TEXT NOT NULL DEFAULT '',
+  occurred_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_adjustments_return_id ON adjustments(return_id, occurred_at);
+
+COMMENT ON COLUMN returns.refund_amount_cents IS 'Refund claimed on the return, in minor units of currency';
+COMMENT ON COLUMN returns.currency IS 'ISO 4217 currency code for amounts on this return';
+COMMENT ON TABLE adjustments IS 'Changes to a refund after filing, applied in occurred_at order';
+COMMENT ON COLUMN adjustments.amount_cents IS 'Signed change in minor units: negative reduces the refund; partial payments record the amount paid';
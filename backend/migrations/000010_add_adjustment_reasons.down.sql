@@ -0,0 +1,11 @@
+-- Restore notifications for status transitions only
+DROP TRIGGER IF EXISTS notify_returns_status_update ON returns;
+CREATE TRIGGER notify_returns_status_update AFTER UPDATE ON returns
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status)
+    EXECUTE FUNCTION notify_return_status();
+
+-- Drop the columns
+ALTER TABLE adjustments DROP CONSTRAINT IF EXISTS adjustments_offset_agency;
+ALTER TABLE adjustments DROP COLUMN IF EXISTS agency;
+ALTER TABLE adjustments DROP COLUMN IF EXISTS reason_code;
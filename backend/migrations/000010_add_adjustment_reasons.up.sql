@@ -0,0 +1,18 @@
+-- Why an adjustment happened and which agency made it, e.g. a Treasury offset
+-- for child support owed to a state agency or an IRS math-error correction
+ALTER TABLE adjustments ADD COLUMN IF NOT EXISTS reason_code TEXT NOT NULL DEFAULT '';
+ALTER TABLE adjustments ADD COLUMN IF NOT EXISTS agency TEXT NOT NULL DEFAULT '';
+
+-- Offsets always name the agency the money went to
+UPDATE adjustments SET agency = 'Unknown' WHERE kind = 'offset' AND agency = '';
+ALTER TABLE adjustments ADD CONSTRAINT adjustments_offset_agency CHECK (kind <> 'offset' OR agency <> '');
+
+-- Recording an adjustment touches the return, so notify on every change rather
+-- than only on status transitions; stream subscribers reload the whole return
+DROP TRIGGER IF EXISTS notify_returns_status_update ON returns;
+CREATE TRIGGER notify_returns_status_update AFTER UPDATE ON returns
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_return_status();
+
+COMMENT ON COLUMN adjustments.reason_code IS 'Machine-readable cause, e.g. child_support or math_error';
+COMMENT ON COLUMN adjustments.agency IS 'Agency that made the adjustment or received the offset';
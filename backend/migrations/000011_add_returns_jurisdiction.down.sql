@@ -0,0 +1,5 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_returns_filing_jurisdiction;
+
+-- Drop the column
+ALTER TABLE returns DROP COLUMN IF EXISTS jurisdiction;
@@ -0,0 +1,8 @@
+-- A filing produces one federal return and a return per state; existing rows are federal
+ALTER TABLE returns ADD COLUMN IF NOT EXISTS jurisdiction TEXT NOT NULL DEFAULT 'federal'
+  CHECK (jurisdiction = 'federal' OR jurisdiction ~ '^[A-Z]{2}$');
+
+-- At most one return per jurisdiction in a filing; also serves filing lookups
+CREATE UNIQUE INDEX IF NOT EXISTS idx_returns_filing_jurisdiction ON returns(filing_id, jurisdiction);
+
+COMMENT ON COLUMN returns.jurisdiction IS 'federal, or the USPS code of the state issuing the refund';
@@ -0,0 +1,2 @@
+-- Drop the column
+ALTER TABLE returns DROP COLUMN IF EXISTS tax_year;
@@ -0,0 +1,8 @@
+-- The tax year a return reports on; it is filed in the following year, so
+-- existing rows take the year before they were created
+ALTER TABLE returns ADD COLUMN IF NOT EXISTS tax_year SMALLINT;
+UPDATE returns SET tax_year = EXTRACT(YEAR FROM created_at)::smallint - 1 WHERE tax_year IS NULL;
+ALTER TABLE returns ALTER COLUMN tax_year SET NOT NULL;
+ALTER TABLE returns ADD CONSTRAINT returns_tax_year_check CHECK (tax_year BETWEEN 2000 AND 2100);
+
+COMMENT ON COLUMN returns.tax_year IS 'Tax year the return reports on; filed during the following calendar year';
@@ -0,0 +1,5 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_eta_revisions_return_id;
+
+-- Drop the table
+DROP TABLE IF EXISTS eta_revisions;
@@ -0,0 +1,21 @@
+-- Every estimate a return has been given, so a moved ETA can be explained and
+-- estimates compared with when refunds actually arrived
+CREATE TABLE IF NOT EXISTS eta_revisions (
+  id BIGSERIAL PRIMARY KEY,
+  return_id TEXT NOT NULL REFERENCES returns(return_id) ON DELETE CASCADE,
+  stage TEXT NOT NULL,
+  eta_date DATE NOT NULL,
+  confidence REAL NOT NULL CHECK (confidence >= 0 AND confidence <= 1),
+  reason TEXT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_eta_revisions_return_id ON eta_revisions(return_id, id);
+
+-- Existing estimates become each return's first revision
+INSERT INTO eta_revisions (return_id, stage, eta_date, confidence, reason, created_at)
+SELECT return_id, status, eta_date, COALESCE(confidence, 0), 'initial', updated_at
+FROM returns
+WHERE eta_date IS NOT NULL;
+
+COMMENT ON COLUMN eta_revisions.stage IS 'Status of the return when the estimate was made';
@@ -0,0 +1,5 @@
+-- Drop the table
+DROP TABLE IF EXISTS eta_calibrations;
+
+-- Drop the column
+ALTER TABLE eta_revisions DROP COLUMN IF EXISTS raw_confidence;
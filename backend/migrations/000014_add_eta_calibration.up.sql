@@ -0,0 +1,16 @@
+-- The engine's confidence before calibration, which the mapping is fitted on;
+-- existing revisions were never calibrated, so their stated confidence is raw
+ALTER TABLE eta_revisions ADD COLUMN IF NOT EXISTS raw_confidence REAL;
+UPDATE eta_revisions SET raw_confidence = confidence WHERE raw_confidence IS NULL;
+ALTER TABLE eta_revisions ALTER COLUMN raw_confidence SET NOT NULL;
+ALTER TABLE eta_revisions ADD CONSTRAINT eta_revisions_raw_confidence_check CHECK (raw_confidence >= 0 AND raw_confidence <= 1);
+
+-- Each fitted recalibration mapping; the newest one is applied
+CREATE TABLE IF NOT EXISTS eta_calibrations (
+  id BIGSERIAL PRIMARY KEY,
+  predictions INTEGER NOT NULL CHECK (predictions > 0),
+  points JSONB NOT NULL,
+  fitted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+COMMENT ON COLUMN eta_calibrations.points IS 'Raw to calibrated confidence pairs, ascending; values between them are interpolated';
@@ -0,0 +1,6 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_returns_stalled_at;
+
+-- Drop the columns
+ALTER TABLE returns DROP COLUMN IF EXISTS stalled_reason;
+ALTER TABLE returns DROP COLUMN IF EXISTS stalled_at;
@@ -0,0 +1,10 @@
+-- Set by the stall detector when a return has sat in ACCEPTED or REVIEW far
+-- longer than normal; cleared when the return moves to another stage
+ALTER TABLE returns ADD COLUMN IF NOT EXISTS stalled_at TIMESTAMPTZ;
+ALTER TABLE returns ADD COLUMN IF NOT EXISTS stalled_reason TEXT;
+
+-- Only stalled returns are listed, so index just those
+CREATE INDEX IF NOT EXISTS idx_returns_stalled_at ON returns(stalled_at) WHERE stalled_at IS NOT NULL;
+
+COMMENT ON COLUMN returns.stalled_at IS 'When the stall detector flagged the return in its current stage; NULL otherwise';
+COMMENT ON COLUMN returns.stalled_reason IS 'How long the return had been in its stage and the threshold it passed';
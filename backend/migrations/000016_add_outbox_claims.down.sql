@@ -0,0 +1,5 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_outbox_unpublished_aggregate;
+
+-- Drop the column
+ALTER TABLE outbox DROP COLUMN IF EXISTS claimed_until;
@@ -0,0 +1,8 @@
+-- The relay leases a batch, commits, and publishes outside the transaction;
+-- claimed_until keeps other relays off the batch while it is in flight
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMPTZ;
+
+-- Claims skip returns that already have an event in flight elsewhere
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished_aggregate ON outbox(aggregate_id) WHERE published_at IS NULL;
+
+COMMENT ON COLUMN outbox.claimed_until IS 'Lease held by the relay publishing the event; NULL or past when free';
@@ -0,0 +1,25 @@
+-- Drop the adjustment notifications
+DROP TRIGGER IF EXISTS notify_adjustments_insert ON adjustments;
+DROP FUNCTION IF EXISTS notify_return_adjustment();
+
+-- Restore notifications on every update, without an event kind
+CREATE OR REPLACE FUNCTION notify_return_status()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify(
+        'return_status',
+        json_build_object(
+            'return_id', NEW.return_id,
+            'status', NEW.status
+        )::text
+    );
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS notify_returns_status_update ON returns;
+CREATE TRIGGER notify_returns_status_update AFTER UPDATE ON returns
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_return_status();
+
+COMMENT ON FUNCTION notify_return_status() IS 'Sends NOTIFY return_status with {return_id, status} on status transitions';
@@ -0,0 +1,45 @@
+-- Migration 000010 made every update of a return notify stream subscribers, so
+-- unrelated writes such as stall flags woke them as transitions. Notify on
+-- status changes only, notify separately when an adjustment is recorded, and
+-- say which kind of change it was in the payload.
+CREATE OR REPLACE FUNCTION notify_return_status()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify(
+        'return_status',
+        json_build_object(
+            'return_id', NEW.return_id,
+            'status', NEW.status,
+            'event', 'transition'
+        )::text
+    );
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS notify_returns_status_update ON returns;
+CREATE TRIGGER notify_returns_status_update AFTER UPDATE ON returns
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status)
+    EXECUTE FUNCTION notify_return_status();
+
+CREATE OR REPLACE FUNCTION notify_return_adjustment()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify(
+        'return_status',
+        json_build_object(
+            'return_id', NEW.return_id,
+            'status', (SELECT status FROM returns WHERE return_id = NEW.return_id),
+            'event', 'adjustment'
+        )::text
+    );
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER notify_adjustments_insert AFTER INSERT ON adjustments
+    FOR EACH ROW EXECUTE FUNCTION notify_return_adjustment();
+
+COMMENT ON FUNCTION notify_return_status() IS 'Sends NOTIFY return_status with {return_id, status, event: transition} on status transitions';
+COMMENT ON FUNCTION notify_return_adjustment() IS 'Sends NOTIFY return_status with {return_id, status, event: adjustment} when an adjustment is recorded';
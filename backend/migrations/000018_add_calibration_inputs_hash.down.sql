@@ -0,0 +1 @@
+ALTER TABLE eta_calibrations DROP COLUMN IF EXISTS inputs_hash;
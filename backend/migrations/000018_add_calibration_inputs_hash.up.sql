@@ -0,0 +1,7 @@
+-- Fingerprint of the estimates a calibration was fitted on, so a replica can
+-- tell a new set of completed returns from one already fitted even when the
+-- number of estimates is the same. Existing calibrations have none and are
+-- refitted on the next run.
+ALTER TABLE eta_calibrations ADD COLUMN IF NOT EXISTS inputs_hash TEXT;
+
+COMMENT ON COLUMN eta_calibrations.inputs_hash IS 'md5 of the revisions fitted and the days their returns completed';
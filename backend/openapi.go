@@ -0,0 +1,10 @@
+// Package refunddemo embeds the hand-maintained API contract so the server and
+// the contract checker share one copy of it
+package refunddemo
+
+import _ "embed"
+
+// OpenAPISpec is openapi.yaml as shipped with the binary
+//
+//go:embed openapi.yaml
+var OpenAPISpec []byte
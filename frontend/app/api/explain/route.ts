@@ -1,4 +1,5 @@
 import { NextRequest } from 'next/server'
+import { backendHeaders } from '@/lib/proxy'
 
 const API_BASE_URL = process.env.API_URL || process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'
 
@@ -11,7 +12,8 @@ export async function POST(request: NextRequest) {
     if (use_backend) {
       const response = await fetch(`${API_BASE_URL}/v1/status/explain`, {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
+        // Forward the bearer token and trace context to the backend
+        headers: backendHeaders(request, { 'Content-Type': 'application/json' }),
         body: JSON.stringify({ return_id, question })
       })
       
@@ -1,4 +1,5 @@
 import { NextRequest, NextResponse } from 'next/server'
+import { backendHeaders } from '@/lib/proxy'
 
 const API_BASE_URL = process.env.API_URL || process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'
 
@@ -9,7 +10,11 @@ export async function GET(request: NextRequest) {
 
     // If id is provided, fetch from backend
     if (id) {
-      const response = await fetch(`${API_BASE_URL}/v1/status/${id}`)
+      // Forward the caller's bearer token and trace context so the backend can
+      // authorize the return and continue the trace
+      const response = await fetch(`${API_BASE_URL}/v1/status/${id}`, {
+        headers: backendHeaders(request),
+      })
       if (!response.ok) {
         return NextResponse.json(
           { error: 'Failed to fetch refund status from backend' },
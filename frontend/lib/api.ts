@@ -54,21 +54,23 @@ export const api = {
     return response.json();
   },
 
-  // Get refund status
+  // Get refund status. /v1 needs a bearer token, so this goes through the
+  // app's /api/status route, which attaches one (see lib/proxy.ts)
   async getRefundStatus(returnId: string): Promise<RefundStatus> {
-    const response = await fetch(`${API_BASE_URL}/v1/status/${returnId}`);
+    const response = await fetch(`/api/status?id=${encodeURIComponent(returnId)}`);
     if (!response.ok) throw new Error('Failed to fetch refund status');
     return response.json();
   },
 
-  // Get refund explanation (SSE stream)
+  // Get refund explanation (SSE stream), through the app's /api/explain route
+  // like getRefundStatus
   async explainRefund(returnId: string, question: string): Promise<ReadableStream> {
-    const response = await fetch(`${API_BASE_URL}/v1/status/explain`, {
+    const response = await fetch('/api/explain', {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
       },
-      body: JSON.stringify({ return_id: returnId, question }),
+      body: JSON.stringify({ return_id: returnId, question, use_backend: true }),
     });
     if (!response.ok) throw new Error('Failed to get explanation');
     if (!response.body) throw new Error('No response body');
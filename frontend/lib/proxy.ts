@@ -0,0 +1,53 @@
+import { createHmac } from 'node:crypto'
+import { NextRequest } from 'next/server'
+
+// Headers forwarded from the browser request to the Go backend: the bearer
+// token, W3C trace context, request ID and the client address the Next.js
+// server recorded, which the backend uses for per-IP rate limits when it
+// trusts this proxy (TRUSTED_PROXIES)
+const FORWARDED_HEADERS = ['authorization', 'traceparent', 'tracestate', 'x-request-id', 'x-forwarded-for']
+
+// Demo sign-in: with DEMO_JWT_SECRET set (the backend's JWT_HS256_SECRET), a
+// browser request without a bearer token is sent as DEMO_OWNER_ID, the owner
+// of the seeded returns. Leave it unset anywhere real users sign in.
+const DEMO_JWT_SECRET = process.env.DEMO_JWT_SECRET || ''
+const DEMO_OWNER_ID = process.env.DEMO_OWNER_ID || 'demo-user'
+const DEMO_TOKEN_TTL_SECONDS = 5 * 60
+
+function randomHex(bytes: number): string {
+  const buf = new Uint8Array(bytes)
+  crypto.getRandomValues(buf)
+  return Array.from(buf, (b) => b.toString(16).padStart(2, '0')).join('')
+}
+
+function base64url(data: string | Buffer): string {
+  return Buffer.from(data).toString('base64url')
+}
+
+// Mint a short-lived HS256 token for the demo owner, as cmd/devtoken does
+function demoToken(): string {
+  const now = Math.floor(Date.now() / 1000)
+  const header = base64url(JSON.stringify({ alg: 'HS256', typ: 'JWT' }))
+  const claims = base64url(JSON.stringify({ sub: DEMO_OWNER_ID, iat: now, exp: now + DEMO_TOKEN_TTL_SECONDS }))
+  const signature = base64url(createHmac('sha256', DEMO_JWT_SECRET).update(`${header}.${claims}`).digest())
+  return `${header}.${claims}.${signature}`
+}
+
+// Build headers for a backend call: forwards the bearer token, client address
+// and W3C trace context, starting a new sampled trace when the caller didn't
+// send one so the proxy hop and the backend spans share a trace ID. Without a
+// bearer token the demo owner's is attached when demo sign-in is configured.
+export function backendHeaders(request: NextRequest, extra: Record<string, string> = {}): Record<string, string> {
+  const headers: Record<string, string> = { ...extra }
+  for (const name of FORWARDED_HEADERS) {
+    const value = request.headers.get(name)
+    if (value) headers[name] = value
+  }
+  if (!headers.authorization && DEMO_JWT_SECRET) {
+    headers.authorization = `Bearer ${demoToken()}`
+  }
+  if (!headers.traceparent) {
+    headers.traceparent = `00-${randomHex(16)}-${randomHex(8)}-01`
+  }
+  return headers
+}